@@ -0,0 +1,8 @@
+// Package api embeds the OpenAPI document describing the HTTP API so the
+// server can serve it.
+package api
+
+import _ "embed"
+
+//go:embed openapi.json
+var Spec []byte
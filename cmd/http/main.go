@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
 	"github.com/ahmadabdelrazik/jasad/internal/application"
+	"github.com/ahmadabdelrazik/jasad/internal/telemetry"
 	"github.com/ahmadabdelrazik/jasad/pkg/config"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -14,7 +21,15 @@ func main() {
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
-	cfg, err := config.Load()
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), *cfg, "jasad-http")
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
@@ -27,4 +42,11 @@ func main() {
 	if err := app.Serve(); err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("can't flush traces")
+	}
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+)
+
+// checkIntegrity reports inconsistent data, it fails if any is found so it
+// can run from cron or CI.
+func checkIntegrity(ctx context.Context, cfg *config.Config, args []string) error {
+	if err := parseFlags(flag.NewFlagSet("check", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	issues, err := models.Integrity.Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(issues) == 0 {
+		fmt.Println("no issues found")
+		return nil
+	}
+
+	for _, issue := range issues {
+		fmt.Printf("%s: %d %s, e.g. ids %v\n", issue.Check, issue.Count, issue.Description, issue.IDs)
+	}
+
+	return errors.New("integrity checks failed")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/ahmadabdelrazik/jasad/internal/events"
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// exportExercises writes the whole catalog as a JSON array in the format
+// importExercises reads.
+func exportExercises(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("exercises export", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write to instead of stdout")
+
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	exercises, _, err := models.Exercises.Search(ctx, "", "", model.Filters{
+		Page:         1,
+		PageSize:     math.MaxInt32,
+		Sort:         "id",
+		SortSafeList: []string{"id"},
+	})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	if err := enc.Encode(exercises); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Printf("exported %d exercises to %s\n", len(exercises), *output)
+	}
+
+	return nil
+}
+
+// importExercises creates the exercises of a JSON array, updating the ones
+// that already exist with the same name. Every exercise is validated
+// before any is saved, and updates are sent to the webhooks subscribed to
+// exercise.updated like the ones made through the API.
+func importExercises(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("exercises import", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var exercises []*model.Exercise
+	if err := json.Unmarshal(data, &exercises); err != nil {
+		return fmt.Errorf("reading %s: %w", fs.Arg(0), err)
+	}
+
+	for i, exercise := range exercises {
+		v := validator.New()
+		exercise.Validate(v)
+
+		if _, err := model.GetMuscle(string(exercise.Muscle)); err != nil {
+			v.AddError("muscle", "invalid muscle")
+		}
+
+		if !v.Valid() {
+			return fmt.Errorf("exercise %d (%q) is invalid: %v", i+1, exercise.Name, v.Errors)
+		}
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	emitter := events.New(models)
+
+	created, updated := 0, 0
+
+	for _, exercise := range exercises {
+		existing, err := models.Exercises.GetByName(ctx, exercise.Name)
+
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			if err := models.Exercises.Create(ctx, exercise); err != nil {
+				return fmt.Errorf("creating %q: %w", exercise.Name, err)
+			}
+			created++
+		case err != nil:
+			return err
+		default:
+			exercise.ID = existing.ID
+			exercise.Version = existing.Version
+
+			if *exercise == *existing {
+				continue
+			}
+
+			if err := models.Exercises.Update(ctx, exercise); err != nil {
+				return fmt.Errorf("updating %q: %w", exercise.Name, err)
+			}
+			emitter.Emit(ctx, model.EventExerciseUpdated, 0, exercise)
+			updated++
+		}
+	}
+
+	fmt.Printf("created %d and updated %d of %d exercises\n", created, updated, len(exercises))
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// Command jasadctl runs admin tasks against the database: managing users
+// and their sessions, importing and exporting the exercise catalog,
+// applying migrations and checking data integrity.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+const usage = `usage: jasadctl <command> [arguments]
+
+commands:
+  users list                        list users
+  users role <email> <role>         set the role of a user, admin or user
+  sessions list <email>             list the active sessions of a user
+  sessions revoke <email>           log a user out of all sessions
+  exercises export [-o file]        write the exercise catalog as JSON
+  exercises import <file>           create or update exercises from JSON
+  migrate up [-path dir]            apply pending migrations
+  migrate down [-path dir] [-steps n | -all]
+                                    roll back migrations, the latest by default
+  migrate version [-path dir]       print the database and latest versions
+  migrate status [-path dir]        list migrations and whether they're applied
+  check                             run data integrity checks
+`
+
+// command runs a subcommand with the arguments following its name.
+type command func(ctx context.Context, cfg *config.Config, args []string) error
+
+var commands = map[string]command{
+	"users list":       listUsers,
+	"users role":       setUserRole,
+	"sessions list":    listSessions,
+	"sessions revoke":  revokeSessions,
+	"exercises export": exportExercises,
+	"exercises import": importExercises,
+	"migrate up":       migrateUp,
+	"migrate down":     migrateDown,
+	"migrate version":  migrateVersion,
+	"migrate status":   migrateStatus,
+	"check":            checkIntegrity,
+}
+
+// errUsage is returned for invalid arguments, the usage is printed.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	run, args := lookup(os.Args[1:])
+	if run == nil {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load("jasadctl", nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jasadctl:", err)
+		os.Exit(1)
+	}
+
+	// interrupting cancels the queries in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err = run(ctx, cfg, args)
+	stop()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jasadctl:", err)
+
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		os.Exit(1)
+	}
+}
+
+// lookup returns the command named by the first one or two arguments and
+// the rest of the arguments.
+func lookup(args []string) (command, []string) {
+	for n := min(2, len(args)); n > 0; n-- {
+		if run, ok := commands[strings.Join(args[:n], " ")]; ok {
+			return run, args[n:]
+		}
+	}
+
+	return nil, nil
+}
+
+// parseFlags parses the flags of the command, which takes the given number
+// of positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string, positional int) error {
+	fs.SetOutput(os.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != positional {
+		return errUsage
+	}
+
+	return nil
+}
+
+func openModels(cfg *config.Config) (*model.Model, error) {
+	models, err := model.New(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the database: %w", err)
+	}
+
+	return models, nil
+}
+
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("connecting to the database: %w", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ahmadabdelrazik/jasad/internal/migrate"
+	"github.com/ahmadabdelrazik/jasad/migrations"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+)
+
+// newMigrator parses the flags shared by the migrate commands along with
+// the command's own ones. The migrations embedded in the binary are used
+// unless a directory is given.
+func newMigrator(cfg *config.Config, flags *flag.FlagSet, args []string) (*migrate.Migrator, error) {
+	path := flags.String("path", "", "directory of the migration files, the embedded ones by default")
+
+	if err := parseFlags(flags, args, 0); err != nil {
+		return nil, err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS = migrations.FS
+	if *path != "" {
+		fsys = os.DirFS(*path)
+	}
+
+	return migrate.New(db, fsys, cfg.QueryTimeout)
+}
+
+func migrateUp(ctx context.Context, cfg *config.Config, args []string) error {
+	m, err := newMigrator(cfg, flag.NewFlagSet("migrate up", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.Up()
+	for _, migration := range applied {
+		fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("database is up to date")
+		return nil
+	}
+
+	return err
+}
+
+func migrateDown(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	all := fs.Bool("all", false, "roll back every migration, dropping all data")
+
+	m, err := newMigrator(cfg, fs, args)
+	if err != nil {
+		return err
+	}
+
+	stepsSet := false
+	fs.Visit(func(f *flag.Flag) {
+		stepsSet = stepsSet || f.Name == "steps"
+	})
+
+	if *steps < 1 || *all && stepsSet {
+		return errUsage
+	}
+
+	// Down rolls back everything for 0 steps
+	if *all {
+		*steps = 0
+	}
+
+	rolledBack, err := m.Down(*steps)
+	for _, migration := range rolledBack {
+		fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no migration to roll back")
+		return nil
+	}
+
+	return err
+}
+
+func migrateVersion(ctx context.Context, cfg *config.Config, args []string) error {
+	m, err := newMigrator(cfg, flag.NewFlagSet("migrate version", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("database: %d\nlatest:   %d\n", version, m.Latest())
+
+	return nil
+}
+
+func migrateStatus(ctx context.Context, cfg *config.Config, args []string) error {
+	m, err := newMigrator(cfg, flag.NewFlagSet("migrate status", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil && !errors.Is(err, migrate.ErrDirty) {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+
+	for _, s := range status {
+		applied := "no"
+		if s.Applied {
+			applied = "yes"
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	return err
+}
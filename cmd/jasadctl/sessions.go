@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+)
+
+func listSessions(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("sessions list", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	user, err := models.Users.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+
+	sessions, err := models.Tokens.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROLE\tCREATED\tEXPIRES")
+
+	for _, session := range sessions {
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\n",
+			session.Role,
+			session.CreatedAt.Format(time.RFC3339),
+			session.ExpiresAt.Format(time.RFC3339),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func revokeSessions(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	user, err := models.Users.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+
+	if err := models.Tokens.RevokeUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("revoked the sessions of %s\n", user.Email)
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+)
+
+func listUsers(ctx context.Context, cfg *config.Config, args []string) error {
+	if err := parseFlags(flag.NewFlagSet("users list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	users, err := models.Users.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tROLE")
+
+	for _, user := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", user.ID, user.Email, user.Name, user.Role)
+	}
+
+	return tw.Flush()
+}
+
+// setUserRole changes the role of the user. Sessions hold the role they
+// were created with, so the user is logged out of all of them.
+func setUserRole(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("users role", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+
+	role, err := model.GetRole(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		return err
+	}
+
+	user, err := models.Users.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("user %s: %w", fs.Arg(0), err)
+	}
+
+	if user.Role == role {
+		fmt.Printf("%s is already %s\n", user.Email, role)
+		return nil
+	}
+
+	previous := user.Role
+	user.Role = role
+
+	if err := models.Users.Update(ctx, user); err != nil {
+		return err
+	}
+
+	err = models.Audit.Record(ctx, &model.AuditEntry{
+		UserID:  user.ID,
+		Action:  model.AuditRoleChanged,
+		Details: map[string]any{"from": previous, "to": role, "by": "jasadctl"},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := models.Tokens.RevokeUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s, their sessions were revoked\n", user.Email, role)
+
+	return nil
+}
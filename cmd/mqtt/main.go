@@ -0,0 +1,169 @@
+// Command mqtt ingests the events smart gym equipment and wearables publish
+// to the MQTT broker into the sessions of their users.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/ingest"
+	"github.com/ahmadabdelrazik/jasad/internal/migrate"
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/internal/telemetry"
+	"github.com/ahmadabdelrazik/jasad/migrations"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog/pkgerrors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func main() {
+	// initialize zerolog
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), *cfg, "jasad-mqtt")
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	models, err := model.New(*cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	// events are written with the schema of this binary
+	m, err := migrate.New(models.DB, migrations.FS, cfg.QueryTimeout)
+	if err == nil {
+		err = m.Check(context.Background())
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	ingester := ingest.New(models)
+
+	// the broker drops the session of a client when another one connects
+	// with the same id, every instance needs its own.
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't get the host name for the client id")
+	}
+
+	clientID := cfg.MQTTClientID + "-" + hostname
+	topic := ingest.SharedTopicFilter(cfg.MQTTShareGroup)
+
+	// persistent session so events published while restarting are
+	// delivered once reconnected.
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.MQTTBroker).
+		SetClientID(clientID).
+		SetUsername(cfg.MQTTUsername).
+		SetPassword(cfg.MQTTPassword).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetOrderMatters(false)
+
+	// subscribe again on every reconnect, in case the broker lost the
+	// session.
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		token := client.Subscribe(topic, 1, func(_ mqtt.Client, msg mqtt.Message) {
+			handleMessage(ingester, msg)
+		})
+
+		if token.Wait() && token.Error() != nil {
+			log.Error().Err(token.Error()).Str("topic", topic).Msg("can't subscribe")
+			return
+		}
+
+		log.Info().Str("broker", cfg.MQTTBroker).Str("client_id", clientID).Str("topic", topic).Msg("subscribed")
+	})
+
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Warn().Err(err).Msg("connection to broker lost")
+	})
+
+	client := mqtt.NewClient(opts)
+
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		log.Fatal().Err(token.Error()).Str("broker", cfg.MQTTBroker).Msg("can't connect to broker")
+	}
+
+	metricsSrv := serveMetrics(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+
+	log.Info().Msg("shutting down ingester")
+
+	// give in flight events time to be handled
+	client.Disconnect(uint((5 * time.Second).Milliseconds()))
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(flushCtx); err != nil {
+			log.Error().Err(err).Msg("can't stop serving metrics")
+		}
+	}
+
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error().Err(err).Msg("can't flush traces")
+	}
+}
+
+var tracer = otel.Tracer("github.com/ahmadabdelrazik/jasad/cmd/mqtt")
+
+func handleMessage(ingester *ingest.Ingester, msg mqtt.Message) {
+	logger := log.With().Str("topic", msg.Topic()).Uint16("message_id", msg.MessageID()).Logger()
+
+	userID, err := ingest.UserFromTopic(msg.Topic())
+	if err != nil {
+		logger.Warn().Err(err).Msg("dropping event")
+		return
+	}
+
+	ctx, span := tracer.Start(context.Background(), "mqtt.ingest", trace.WithAttributes(
+		attribute.String("messaging.destination.name", msg.Topic()),
+		attribute.Int("user.id", userID),
+	))
+	defer span.End()
+
+	err = ingester.Handle(ctx, userID, msg.Payload())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	var invalid ingest.ValidationError
+
+	switch {
+	case err == nil:
+		logger.Debug().Int("user_id", userID).Msg("event ingested")
+	case errors.As(err, &invalid), errors.Is(err, model.ErrNotFound):
+		logger.Warn().Err(err).Int("user_id", userID).Msg("dropping event")
+	default:
+		logger.Error().Err(err).Int("user_id", userID).Msg("can't ingest event")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// serveMetrics serves the metrics of the ingester, like the events it
+// emits, on /metrics of the metrics port. Scrapes have to carry the metrics
+// token as a bearer token like the ones of the HTTP server. It returns nil
+// when no token is configured, the metrics aren't served then.
+func serveMetrics(cfg *config.Config) *http.Server {
+	if cfg.MetricsToken == "" {
+		return nil
+	}
+
+	promHandler := promhttp.Handler()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+cfg.MetricsToken)) != 1 {
+			http.Error(w, "invalid metrics token", http.StatusUnauthorized)
+			return
+		}
+
+		promHandler.ServeHTTP(w, r)
+	})
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.MQTTMetricsPort),
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  time.Minute,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("serving metrics")
+
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("can't serve metrics")
+		}
+	}()
+
+	return srv
+}
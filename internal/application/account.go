@@ -0,0 +1,258 @@
+package application
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// allRecords are filters returning every record in a single page, used when
+// exporting the user's data.
+var allRecords = model.Filters{
+	Page:         1,
+	PageSize:     math.MaxInt32,
+	Sort:         "id",
+	SortSafeList: []string{"id"},
+}
+
+// exportHandler returns everything stored about the user either as a ZIP
+// archive of JSON files or as a single JSON document.
+func (app *Application) exportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	authUser, ok := getUser(r)
+	if !ok { // if there is no user in context
+		UnauthorizedResponse(w, r)
+		return
+		// if the user is not exporting his data and isn't an admin.
+	} else if authUser.ID != int(id) && authUser.Role != model.RoleAdmin {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	format := app.readString(r.URL.Query(), "format", "zip")
+	v.Check(validator.In(format, []string{"zip", "json"}), "format", "must be zip or json")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	files, err := app.collectUserData(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Audit.Record(r.Context(), &model.AuditEntry{
+		UserID:  int(id),
+		Action:  model.AuditDataExported,
+		Details: map[string]any{"format": format, "by": authUser.ID},
+	})
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("jasad-export-%d-%s", id, time.Now().Format("20060102"))
+
+	if format == "json" {
+		headers := make(http.Header)
+		headers.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+
+		err = app.writeJSON(w, http.StatusOK, envelope(files), headers)
+		if err != nil {
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the archive outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	w.WriteHeader(http.StatusOK)
+
+	// the archive is streamed to the client, errors can only be logged
+	// from here on.
+	zw := zip.NewWriter(w)
+
+	for name, data := range files {
+		f, err := zw.Create(name + ".json")
+		if err != nil {
+			logError(r, err)
+			return
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "\t")
+
+		if err := enc.Encode(data); err != nil {
+			logError(r, err)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		logError(r, err)
+	}
+}
+
+// collectUserData gathers the user's data keyed by the name of the file it
+// is exported to.
+func (app *Application) collectUserData(ctx context.Context, userID int) (map[string]any, error) {
+	user, err := app.models.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workouts, _, err := app.models.Workouts.GetAll(ctx, userID, "", 0, "", allRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, workout := range workouts {
+		workout.SetUnits(user.Units)
+	}
+
+	measurements, _, err := app.models.Measurements.GetAll(ctx, userID, time.Time{}, time.Time{}, allRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, measurement := range measurements {
+		measurement.SetUnits(user.Units)
+	}
+
+	shares, err := app.models.Shares.GetAll(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := app.models.Tokens.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type sessionOutput struct {
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	sessionsOutput := make([]sessionOutput, len(sessions))
+	for i, session := range sessions {
+		sessionsOutput[i] = sessionOutput{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		}
+	}
+
+	schedules, err := app.models.Schedules.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := app.models.Notifications.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	imports, err := app.models.Imports.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	heartRates, err := app.models.HeartRates.GetAll(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	audit, err := app.models.Audit.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"profile":       newUserOutput(user),
+		"workouts":      workouts,
+		"heart_rates":   heartRates,
+		"measurements":  measurements,
+		"shares":        shares,
+		"sessions":      sessionsOutput,
+		"schedules":     schedules,
+		"imports":       imports,
+		"notifications": notifications,
+		"audit":         audit,
+	}, nil
+}
+
+// deleteUserHandler permanently deletes the account with everything it
+// owns and logs it out of all sessions.
+func (app *Application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	authUser, ok := getUser(r)
+	if !ok { // if there is no user in context
+		UnauthorizedResponse(w, r)
+		return
+		// if the user is not deleting his account and isn't an admin.
+	} else if authUser.ID != int(id) && authUser.Role != model.RoleAdmin {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Users.Delete(r.Context(), int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the account is gone already, its sessions left behind don't
+	// authenticate anyone as they don't lead to a user anymore.
+	if err := app.models.Tokens.RevokeUserSessions(r.Context(), int(id)); err != nil {
+		logError(r, err)
+	}
+
+	if authUser.ID == int(id) {
+		cookie := app.sessionCookie("", time.Time{})
+		cookie.MaxAge = -1
+
+		http.SetCookie(w, cookie)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "account deleted successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
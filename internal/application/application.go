@@ -2,57 +2,147 @@ package application
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/ahmadabdelrazik/jasad/internal/events"
+	"github.com/ahmadabdelrazik/jasad/internal/migrate"
 	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/internal/notify"
+	"github.com/ahmadabdelrazik/jasad/migrations"
 	"github.com/ahmadabdelrazik/jasad/pkg/config"
 	"github.com/ahmadabdelrazik/jasad/pkg/validator"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("github.com/ahmadabdelrazik/jasad/internal/application")
+
 type Application struct {
-	cfg    config.Config
-	models *model.Model
-	oauth  OAuthConfig
-	wg     sync.WaitGroup
+	cfg      config.Config
+	models   *model.Model
+	oauth    OAuthConfig
+	channels map[model.NotificationChannel]notify.Channel
+	webhooks *notify.Webhook
+	events   *events.Emitter
+	migrator *migrate.Migrator
+	wg       sync.WaitGroup
+
+	// clients connecting from these send their address in X-Forwarded-For
+	trustedProxies []netip.Prefix
+
+	// ready is unset while shutting down so load balancers stop routing
+	// requests before the server stops accepting them
+	ready atomic.Bool
+
+	// shutdown is cancelled once the server starts shutting down so
+	// long-lived requests like live sessions end instead of holding it up
+	shutdown       context.Context
+	cancelShutdown context.CancelFunc
 }
 
 func New(cfg config.Config) (*Application, error) {
-	model, err := model.New(cfg.DSN)
+	model, err := model.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := prepareSchema(model.DB, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies(cfg.TrustedProxies)
 	if err != nil {
 		return nil, err
 	}
+
+	registerMetrics(model)
+
+	shutdown, cancelShutdown := context.WithCancel(context.Background())
+
 	return &Application{
-		cfg:    cfg,
-		models: model,
-		oauth:  newOAuthConfig(cfg),
+		cfg:      cfg,
+		models:   model,
+		oauth:    newOAuthConfig(cfg),
+		channels: newChannels(cfg),
+		webhooks: notify.NewWebhook(),
+		events:   events.New(model),
+		migrator: migrator,
+
+		trustedProxies: trustedProxies,
+		shutdown:       shutdown,
+		cancelShutdown: cancelShutdown,
 	}, nil
 }
 
+// prepareSchema applies the pending migrations if MIGRATE_ON_START is set,
+// otherwise it refuses to start on a database behind the migrations.
+func prepareSchema(db *sql.DB, cfg config.Config) (*migrate.Migrator, error) {
+	m, err := migrate.New(db, migrations.FS, cfg.QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MigrateOnStart {
+		applied, err := m.Up()
+		for _, migration := range applied {
+			log.Info().Uint("version", migration.Version).Str("name", migration.Name).Msg("applied migration")
+		}
+
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return nil, err
+		}
+	}
+
+	if err := m.Check(context.Background()); err != nil {
+		return nil, fmt.Errorf("%w, run jasadctl migrate up or set MIGRATE_ON_START", err)
+	}
+
+	return m, nil
+}
+
 func (app *Application) Serve() error {
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.cfg.Port),
 		Handler:      app.Routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  app.cfg.ServerIdleTimeout,
+		ReadTimeout:  app.cfg.ServerReadTimeout,
+		WriteTimeout: app.cfg.ServerWriteTimeout,
 	}
 
+	// Shutdown doesn't wait for hijacked or streaming connections to end
+	// on their own, they're told to end instead.
+	srv.RegisterOnShutdown(app.cancelShutdown)
+
 	shutdownError := make(chan error)
 
+	// stops the background workers on shutdown
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+
+	app.background(func() {
+		app.runNotifier(workersCtx)
+	})
+
+	app.background(func() {
+		app.runWebhookDispatcher(workersCtx)
+	})
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -62,24 +152,35 @@ func (app *Application) Serve() error {
 
 		log.Info().Str("signal", s.String()).Msg("shutting down server")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		// requests are still served while load balancers notice the
+		// instance isn't ready anymore
+		app.ready.Store(false)
+		time.Sleep(app.cfg.ShutdownGracePeriod)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.cfg.ShutdownTimeout)
 		defer cancel()
 
 		err := srv.Shutdown(ctx)
-		if err != nil {
-			shutdownError <- err
-		}
 
 		log.Info().Str("addr", srv.Addr).Msg("completing background tasks")
 
+		// the background tasks are completed even if the server didn't
+		// shut down cleanly.
+		stopWorkers()
+
 		app.wg.Wait()
-		shutdownError <- nil
+		shutdownError <- err
 	}()
 
 	log.Info().Str("addr", srv.Addr).Msg("starting server")
 
+	app.ready.Store(true)
+
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
+		stopWorkers()
+		app.wg.Wait()
+
 		return err
 	}
 
@@ -199,6 +300,40 @@ func (app *Application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// readDate reads a date in the form of 2006-01-02 or a full RFC3339 time.
+func (app *Application) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be a date in the form of YYYY-MM-DD or RFC3339")
+		return defaultValue
+	}
+
+	return t
+}
+
+// readEndDate reads the end of a range like readDate, ranges exclude their
+// end. A date without a time ends the range at the start of the next day so
+// the whole day is included.
+func (app *Application) readEndDate(qs url.Values, key string, v *validator.Validator) time.Time {
+	t := app.readDate(qs, key, time.Time{}, v)
+
+	if _, err := time.Parse(time.DateOnly, qs.Get(key)); err == nil {
+		return t.AddDate(0, 0, 1)
+	}
+
+	return t
+}
+
 func (app *Application) readCSV(qs url.Values, key string, defaultValue []string) []string {
 	csv := qs.Get(key)
 
@@ -15,12 +15,18 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
+// OAuthConfig holds the OAuth providers users can sign in with, the ones
+// without a client configured are nil.
 type OAuthConfig struct {
-	Google oauth2.Config
+	Google *oauth2.Config
 }
 
 func newOAuthConfig(cfg config.Config) OAuthConfig {
-	google := oauth2.Config{
+	if cfg.GoogleClientID == "" {
+		return OAuthConfig{}
+	}
+
+	google := &oauth2.Config{
 		ClientID:     cfg.GoogleClientID,
 		ClientSecret: cfg.GoogleClientSecret,
 		Endpoint:     google.Endpoint,
@@ -51,6 +57,11 @@ type InfoToken struct {
 // googleLoginHandler redirect user to google's login page to consent using the
 // application
 func (app *Application) googleLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oauth.Google == nil {
+		NotFoundResponse(w, r)
+		return
+	}
+
 	url := app.oauth.Google.AuthCodeURL("random-state-to-protect-from-csrf")
 
 	http.Redirect(w, r, url, http.StatusSeeOther)
@@ -59,6 +70,11 @@ func (app *Application) googleLoginHandler(w http.ResponseWriter, r *http.Reques
 // googleCallbackHandler receive auth code and exchange it with user info from
 // google auth server.
 func (app *Application) googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oauth.Google == nil {
+		NotFoundResponse(w, r)
+		return
+	}
+
 	qs := r.URL.Query()
 
 	state := app.readString(qs, "state", "")
@@ -97,29 +113,20 @@ func (app *Application) googleCallbackHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	user, err := fetchUser(app.models, info)
+	user, err := fetchUser(r.Context(), app.models, info)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 		return
 	}
 
 	// Produce Session Token to give to the user.
-	sessionToken, err := app.models.Tokens.GenerateToken(user)
+	sessionToken, err := app.models.Tokens.GenerateToken(r.Context(), user)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:       "id",
-		Value:      sessionToken,
-		Expires:    time.Now().Add(3 * 24 * time.Hour),
-		RawExpires: "",
-		MaxAge:     0,
-		Secure:     true,
-		HttpOnly:   true,
-		SameSite:   http.SameSiteLaxMode,
-	})
+	http.SetCookie(w, app.sessionCookie(sessionToken, time.Now().Add(app.cfg.SessionTTL)))
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "logged in successfully"}, nil)
 	if err != nil {
@@ -129,19 +136,21 @@ func (app *Application) googleCallbackHandler(w http.ResponseWriter, r *http.Req
 
 // fetchUser returns the user by email, or create a new one if not
 // registered
-func fetchUser(models *model.Model, info InfoToken) (*model.User, error) {
-	user, err := models.Users.GetByEmail(info.Email)
+func fetchUser(ctx context.Context, models *model.Model, info InfoToken) (*model.User, error) {
+	user, err := models.Users.GetByEmail(ctx, info.Email)
 	if user != nil { // user is registered on the system
 		return user, nil
 	} else if errors.Is(err, model.ErrNotFound) { // user is not registered
 
 		user := &model.User{
-			Name:  info.Name,
-			Email: info.Email,
-			Role:  model.RoleUser,
+			Name:    info.Name,
+			Email:   info.Email,
+			Role:    model.RoleUser,
+			Profile: model.DefaultProfile,
 		}
+		user.AvatarURL = info.Picture
 
-		if err := models.Users.Create(user); err != nil {
+		if err := models.Users.Create(ctx, user); err != nil {
 			return nil, err
 		}
 
@@ -0,0 +1,347 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// InputMeasurement is a body measurement as sent by the user, the weight is
+// in the user's preferred unit.
+type InputMeasurement struct {
+	MeasuredAt     *time.Time            `json:"measured_at"`
+	Weight         *float64              `json:"weight"`
+	BodyFat        *float64              `json:"body_fat"`
+	Circumferences *model.Circumferences `json:"circumferences"`
+	Photos         []model.ProgressPhoto `json:"photos"`
+	Notes          *string               `json:"notes"`
+}
+
+// apply copies the values set in the input to the measurement.
+func (input InputMeasurement) apply(m *model.BodyMeasurement, units model.Units) {
+	if input.MeasuredAt != nil {
+		m.MeasuredAt = *input.MeasuredAt
+	}
+	if input.Weight != nil {
+		m.Weight = model.NewWeight(*input.Weight, units.Weight)
+	}
+	if input.BodyFat != nil {
+		m.BodyFat = *input.BodyFat
+	}
+	if input.Circumferences != nil {
+		m.Circumferences = *input.Circumferences
+	}
+	if input.Photos != nil {
+		m.Photos = input.Photos
+	}
+	if input.Notes != nil {
+		m.Notes = *input.Notes
+	}
+}
+
+func (app *Application) createMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input InputMeasurement
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	// measurements are taken now unless stated otherwise
+	measurement := &model.BodyMeasurement{
+		UserID:     user.ID,
+		MeasuredAt: time.Now(),
+		Photos:     []model.ProgressPhoto{},
+	}
+	input.apply(measurement, user.Units)
+
+	v := validator.New()
+	measurement.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Measurements.Create(r.Context(), measurement); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	measurement.SetUnits(user.Units)
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"measurement": measurement}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getAllMeasurementsHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+		model.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.From = app.readDate(qs, "from", time.Time{}, v)
+	input.To = app.readEndDate(qs, "to", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-measured_at")
+
+	input.Filters.SortSafeList = []string{"id", "measured_at", "-id", "-measured_at"}
+
+	model.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	measurements, metadata, err := app.models.Measurements.GetAll(r.Context(), user.ID, input.From, input.To, input.Filters)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	for _, measurement := range measurements {
+		measurement.SetUnits(user.Units)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"measurements": measurements, "metadata": metadata}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	measurement, err := app.models.Measurements.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	measurement.SetUnits(user.Units)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"measurement": measurement}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	measurement, err := app.models.Measurements.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input InputMeasurement
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	input.apply(measurement, user.Units)
+
+	v := validator.New()
+	measurement.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Measurements.Update(r.Context(), measurement); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	measurement.SetUnits(user.Units)
+
+	err = app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "measurement": measurement},
+		nil,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Measurements.Delete(r.Context(), user.ID, int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "measurement deleted successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// measurementTrendHandler returns the values of a single metric over time
+// along with their moving average over a window of days.
+func (app *Application) measurementTrendHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	metric := app.readString(qs, "metric", "weight")
+	window := app.readInt(qs, "window", 7, v)
+	from := app.readDate(qs, "from", time.Time{}, v)
+	to := app.readEndDate(qs, "to", v)
+
+	v.Check(model.ValidMetric(metric), "metric", "invalid metric")
+	v.Check(window > 0, "window", "must be a positive number")
+	v.Check(window <= 365, "window", "must not be more than 365 days")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	points, err := app.models.Measurements.GetSeries(r.Context(), user.ID, metric, from, to)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	// weights are stored in grams
+	unit := "cm"
+	switch metric {
+	case "weight":
+		unit = string(user.Units.Weight)
+		for i := range points {
+			points[i].Value = model.Weight(points[i].Value).In(user.Units.Weight)
+		}
+	case "body_fat":
+		unit = "%"
+	}
+
+	summary := model.CalculateTrend(points, time.Duration(window)*24*time.Hour)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"metric":  metric,
+		"unit":    unit,
+		"window":  window,
+		"points":  points,
+		"summary": summary,
+	}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// setBodyweight sets the latest bodyweight of the user on the workouts so
+// the load and relative strength of their exercises can be calculated.
+func (app *Application) setBodyweight(ctx context.Context, user *model.User, workouts ...*model.Workout) error {
+	bodyweight, err := app.models.Measurements.GetLatestWeight(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	for _, workout := range workouts {
+		workout.SetBodyweight(bodyweight)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/internal/notify"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// how often due webhook deliveries are looked for
+	dispatcherInterval = 5 * time.Second
+
+	// time a claimed delivery is locked for while being sent
+	deliveryLease = time.Minute
+
+	// deliveries claimed at a time
+	deliveryBatch = 20
+)
+
+// runWebhookDispatcher sends due webhook deliveries until ctx is done.
+func (app *Application) runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(dispatcherInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := app.dispatchWebhooks(ctx); err != nil {
+			log.Error().Err(err).Msg("can't dispatch webhooks")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchWebhooks sends the due deliveries in batches until none are left
+// or ctx is done.
+func (app *Application) dispatchWebhooks(ctx context.Context) error {
+	for ctx.Err() == nil {
+		deliveries, err := app.models.Webhooks.Claim(ctx, deliveryBatch, deliveryLease)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range deliveries {
+			app.dispatchWebhook(ctx, d)
+		}
+
+		if len(deliveries) < deliveryBatch {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (app *Application) dispatchWebhook(ctx context.Context, d *model.WebhookDelivery) {
+	logger := log.With().Int64("delivery_id", d.ID).Int("webhook_id", d.WebhookID).Str("event", d.Event).Logger()
+
+	ctx, span := tracer.Start(ctx, "webhook.deliver", trace.WithAttributes(
+		attribute.Int64("webhook.delivery_id", d.ID),
+		attribute.String("webhook.event", d.Event),
+	))
+	defer span.End()
+
+	sendCtx, cancel := context.WithTimeout(ctx, deliveryLease/2)
+	defer cancel()
+
+	status, err := app.webhooks.Deliver(sendCtx, d.URL, d.Secret, notify.Delivery{
+		ID:      d.ID,
+		Event:   d.Event,
+		Payload: d.Payload,
+	})
+
+	// the outcome is recorded even when shutting down
+	ctx = context.WithoutCancel(ctx)
+
+	if err == nil {
+		if err := app.models.Webhooks.MarkDelivered(ctx, d, status); err != nil {
+			logger.Error().Err(err).Msg("can't mark delivery as delivered")
+		}
+		return
+	}
+
+	logger.Warn().Err(err).AnErr("cause", errors.Unwrap(err)).Int("attempt", d.Attempts).Msg("failed to deliver webhook")
+	span.SetStatus(codes.Error, err.Error())
+
+	if err := app.models.Webhooks.MarkFailed(ctx, d, status, err, time.Now().Add(backoff(d.Attempts))); err != nil {
+		logger.Error().Err(err).Msg("can't mark delivery as failed")
+	}
+}
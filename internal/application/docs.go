@@ -0,0 +1,63 @@
+package application
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/ahmadabdelrazik/jasad/api"
+)
+
+// swaggerUI is the exact release of Swagger UI the docs page loads, bump it
+// deliberately rather than following the latest one.
+const swaggerUI = "https://unpkg.com/swagger-ui-dist@5.17.14"
+
+const docsScript = `window.ui = SwaggerUIBundle({url: "/v1/openapi.json", dom_id: "#swagger-ui"});`
+
+// docsPage renders the OpenAPI document with Swagger UI. Requests sent from
+// the page carry the session cookie, so signing in with Google first makes
+// the authenticated endpoints usable from it.
+var docsPage = fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Jasad API</title>
+	<link rel="stylesheet" href="%[1]s/swagger-ui.css" crossorigin>
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="%[1]s/swagger-ui-bundle.js" crossorigin></script>
+	<script>%[2]s</script>
+</body>
+</html>
+`, swaggerUI, docsScript)
+
+// docsPolicy only lets the docs page run the pinned Swagger UI files and its
+// own inline script, and send requests to the API itself.
+var docsPolicy = fmt.Sprintf(
+	"default-src 'self'; script-src %[1]s/swagger-ui-bundle.js 'sha256-%[2]s'; "+
+		"style-src %[1]s/swagger-ui.css 'unsafe-inline'; img-src 'self' data:; "+
+		"object-src 'none'; base-uri 'none'; frame-ancestors 'none'",
+	swaggerUI, scriptHash(docsScript),
+)
+
+// scriptHash returns the base64 SHA-256 of an inline script as used in
+// content security policies.
+func scriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (app *Application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(api.Spec)
+}
+
+func (app *Application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	w.Write([]byte(docsPage))
+}
@@ -2,6 +2,7 @@ package application
 
 import (
 	"encoding/json"
+	"fmt"
 	"maps"
 	"net/http"
 
@@ -9,17 +10,53 @@ import (
 )
 
 func logError(r *http.Request, err error) {
+	id, _ := getRequestID(r)
+
 	log.Error().
 		Stack().Err(err).
+		Str("request_id", id).
 		Str("request_method", r.Method).
 		Str("request_url", r.URL.String()).
 		Msg("")
 }
 
-func ErrorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
-	env := envelope{"error": message}
+// Stable codes of the problems returned by the API, clients should rely on
+// them instead of the human readable detail.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthenticated    = "unauthenticated"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeAlreadyExists      = "already_exists"
+	CodeEditConflict       = "edit_conflict"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnmatchedExercises = "unmatched_exercises"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+// ErrorResponse writes an RFC 7807 problem details document. The extra
+// members are added next to the standard ones, e.g. the invalid fields of a
+// validation problem.
+func ErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, detail string, extra envelope) {
+	problem := envelope{}
+	maps.Copy(problem, extra)
+
+	problem["type"] = "urn:jasad:problem:" + code
+	problem["title"] = http.StatusText(status)
+	problem["status"] = status
+	problem["code"] = code
+	problem["detail"] = detail
+	problem["instance"] = r.URL.Path
+
+	if id, ok := getRequestID(r); ok {
+		problem["request_id"] = id
+	}
 
-	err := writeJSON(w, status, env, nil)
+	headers := http.Header{"Content-Type": {"application/problem+json"}}
+
+	err := writeJSON(w, status, problem, headers)
 	if err != nil {
 		logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -30,47 +67,56 @@ func ServerErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	logError(r, err)
 
 	message := "the server encountered a problem and could not process your request"
-	ErrorResponse(w, r, http.StatusInternalServerError, message)
+	ErrorResponse(w, r, http.StatusInternalServerError, CodeInternal, message, nil)
 }
 
 func NotFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
 
-	ErrorResponse(w, r, http.StatusNotFound, message)
+	ErrorResponse(w, r, http.StatusNotFound, CodeNotFound, message, nil)
+}
+
+func MethodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+
+	ErrorResponse(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, message, nil)
 }
 
 func BadRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+	ErrorResponse(w, r, http.StatusBadRequest, CodeBadRequest, err.Error(), nil)
 }
 
 func ConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "resource already exists"
-	ErrorResponse(w, r, http.StatusConflict, message)
+	ErrorResponse(w, r, http.StatusConflict, CodeAlreadyExists, message, nil)
 }
 
 func EditConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to edit conflict, please try again"
-	ErrorResponse(w, r, http.StatusConflict, message)
+	ErrorResponse(w, r, http.StatusConflict, CodeEditConflict, message, nil)
 }
 
 func AuthenticationErrorResponse(w http.ResponseWriter, r *http.Request) {
 	message := "Invalid Authentication Credentials"
-	ErrorResponse(w, r, http.StatusUnauthorized, message)
+	ErrorResponse(w, r, http.StatusUnauthorized, CodeUnauthenticated, message, nil)
 }
 
 func UnauthorizedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "Insufficient permission to access the resource"
-	ErrorResponse(w, r, http.StatusForbidden, message)
+	ErrorResponse(w, r, http.StatusForbidden, CodeForbidden, message, nil)
 }
 
 func RateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 
-	ErrorResponse(w, r, http.StatusTooManyRequests, message)
+	ErrorResponse(w, r, http.StatusTooManyRequests, CodeRateLimited, message, nil)
 }
 
-func FailedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	ErrorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// FailedValidationResponse lists every message of every invalid field.
+func FailedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string][]string) {
+	message := "the request contains invalid fields"
+
+	ErrorResponse(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, message, envelope{"errors": errors})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
@@ -81,10 +127,10 @@ func writeJSON(w http.ResponseWriter, status int, data envelope, headers http.He
 
 	js = append(js, '\n')
 
-	maps.Copy(w.Header(), headers)
-
 	w.Header().Set("Content-Type", "application/json")
 
+	maps.Copy(w.Header(), headers)
+
 	w.WriteHeader(status)
 	w.Write(js)
 
@@ -15,6 +15,7 @@ func (app *Application) createExerciseHandler(w http.ResponseWriter, r *http.Req
 		Instructions   string `json:"instructions"`
 		AdditionalInfo string `json:"additional_info"`
 		ImageURL       string `json:"image_url"`
+		Measurement    string `json:"measurement"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -29,12 +30,23 @@ func (app *Application) createExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// exercises are tracked by reps and weight unless stated otherwise
+	measurement := model.Measurement(model.MeasurementRepsAndWeight)
+	if input.Measurement != "" {
+		measurement, err = model.GetMeasurement(input.Measurement)
+		if err != nil {
+			BadRequestResponse(w, r, err)
+			return
+		}
+	}
+
 	exercise := &model.Exercise{
 		Name:           input.Name,
 		Muscle:         muscle,
 		Instructions:   input.Instructions,
 		AdditionalInfo: input.AdditionalInfo,
 		ImageURL:       input.ImageURL,
+		Measurement:    measurement,
 	}
 
 	v := validator.New()
@@ -44,7 +56,7 @@ func (app *Application) createExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := app.models.Exercises.Create(exercise); err != nil {
+	if err := app.models.Exercises.Create(r.Context(), exercise); err != nil {
 		switch {
 		case errors.Is(err, model.ErrAlreadyExists):
 			ConflictResponse(w, r)
@@ -67,7 +79,7 @@ func (app *Application) getExericseHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	exercise, err := app.models.Exercises.Get(int(id))
+	exercise, err := app.models.Exercises.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
@@ -112,7 +124,7 @@ func (app *Application) searchExercisesHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	exercises, metadata, err := app.models.Exercises.Search(input.Name, input.Muscle, input.Filters)
+	exercises, metadata, err := app.models.Exercises.Search(r.Context(), input.Name, input.Muscle, input.Filters)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 		return
@@ -134,6 +146,7 @@ func (app *Application) updateExerciseHandler(w http.ResponseWriter, r *http.Req
 		Instructions   *string `json:"instructions"`
 		AdditionalInfo *string `json:"additional_info"`
 		ImageURL       *string `json:"image_url"`
+		Measurement    *string `json:"measurement"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
@@ -141,7 +154,7 @@ func (app *Application) updateExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	exercise, err := app.models.Exercises.Get(int(id))
+	exercise, err := app.models.Exercises.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
@@ -174,6 +187,28 @@ func (app *Application) updateExerciseHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	v := validator.New()
+
+	if input.Measurement != nil {
+		measurement, err := model.GetMeasurement(*input.Measurement)
+		if err != nil {
+			BadRequestResponse(w, r, err)
+			return
+		}
+
+		// the values workouts recorded wouldn't match the new measurement
+		if measurement != exercise.Measurement {
+			used, err := app.models.Exercises.InUse(r.Context(), exercise.ID)
+			if err != nil {
+				ServerErrorResponse(w, r, err)
+				return
+			}
+
+			v.Check(!used, "measurement", "can't be changed while workouts use the exercise")
+		}
+
+		exercise.Measurement = measurement
+	}
+
 	exercise.Validate(v)
 
 	if !v.Valid() {
@@ -181,7 +216,7 @@ func (app *Application) updateExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := app.models.Exercises.Update(exercise); err != nil {
+	if err := app.models.Exercises.Update(r.Context(), exercise); err != nil {
 		switch {
 		case errors.Is(err, model.ErrEditConflict):
 			EditConflictResponse(w, r)
@@ -193,6 +228,8 @@ func (app *Application) updateExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	app.events.Emit(r.Context(), model.EventExerciseUpdated, 0, exercise)
+
 	err = app.writeJSON(
 		w,
 		http.StatusOK,
@@ -211,7 +248,7 @@ func (app *Application) deleteExerciseHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := app.models.Exercises.Delete(int(id)); err != nil {
+	if err := app.models.Exercises.Delete(r.Context(), int(id)); err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
 			NotFoundResponse(w, r)
@@ -0,0 +1,208 @@
+package application
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+var workoutsCSVHeader = []string{
+	"date", "workout_id", "workout", "order", "block", "exercise", "muscle",
+	"measurement", "set", "reps", "weight", "duration", "distance",
+	"actual_reps", "actual_weight", "actual_duration", "actual_distance",
+	"weight_unit", "distance_unit", "done",
+}
+
+// exportWorkoutsHandler streams the user's workouts as CSV with one row per
+// set or newline delimited JSON with one workout per line. The iCalendar
+// format exports the planned workouts instead, an event per occurrence of
+// the user's schedules, from 3 months ago up to a year ahead by default.
+func (app *Application) exportWorkoutsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	format := app.readString(qs, "format", "csv")
+	from := app.readDate(qs, "from", time.Time{}, v)
+	to := app.readDate(qs, "to", time.Time{}, v)
+
+	v.Check(validator.In(format, []string{"csv", "ndjson", "ics"}), "format", "must be csv, ndjson or ics")
+	v.Check(from.IsZero() || to.IsZero() || from.Before(to), "to", "must be after from")
+
+	loc := user.Location()
+
+	if format == "ics" {
+		today := model.NewDate(time.Now().In(loc))
+
+		fromDate, toDate := today.AddDays(-90), today.AddDays(365)
+		if !from.IsZero() {
+			fromDate = model.NewDate(from)
+		}
+		if !to.IsZero() {
+			toDate = model.NewDate(to)
+		}
+
+		v.Check(!toDate.Before(fromDate.Time), "to", "must not be before from")
+		v.Check(!toDate.After(fromDate.AddDays(731).Time), "to", "must be within 2 years of from")
+
+		if !v.Valid() {
+			FailedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		filename := fmt.Sprintf("jasad-workouts-%s.ics", today.Format("20060102"))
+
+		app.writeCalendar(w, r, user, "Jasad workouts", filename, fromDate, toDate)
+		return
+	}
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var (
+		contentType string
+		each        func(*model.Workout) error
+		finish      func() error
+	)
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+
+		// the header is written along with the first workout so errors
+		// before it can still be reported.
+		header := false
+		writeHeader := func() {
+			if !header {
+				cw.Write(workoutsCSVHeader)
+				header = true
+			}
+		}
+
+		contentType = "text/csv"
+		each = func(workout *model.Workout) error {
+			writeHeader()
+			for _, record := range workoutCSVRecords(workout, loc) {
+				cw.Write(record)
+			}
+			cw.Flush()
+			return cw.Error()
+		}
+		finish = func() error {
+			writeHeader()
+			cw.Flush()
+			return cw.Error()
+		}
+
+	case "ndjson":
+		enc := json.NewEncoder(w)
+
+		contentType = "application/x-ndjson"
+		each = func(workout *model.Workout) error {
+			return enc.Encode(workout)
+		}
+		finish = func() error { return nil }
+	}
+
+	filename := fmt.Sprintf("jasad-workouts-%s.%s", time.Now().In(loc).Format("20060102"), format)
+
+	// the export outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	started := false
+
+	err := app.models.Workouts.Each(r.Context(), user.ID, from, to, func(workout *model.Workout) error {
+		started = true
+		workout.SetUnits(user.Units)
+		return each(workout)
+	})
+
+	if err == nil {
+		err = finish()
+	}
+
+	if err != nil {
+		if !started {
+			w.Header().Del("Content-Disposition")
+			ServerErrorResponse(w, r, err)
+			return
+		}
+
+		// the response is already being streamed, the client gets a
+		// truncated file.
+		logError(r, err)
+	}
+}
+
+// workoutCSVRecords returns a record for every set of the workout.
+func workoutCSVRecords(workout *model.Workout, loc *time.Location) [][]string {
+	records := [][]string{}
+
+	for _, exercise := range workout.Exercises {
+		units := exercise.DisplayUnits()
+
+		for set := 1; set <= exercise.Sets; set++ {
+			records = append(records, []string{
+				workout.CreatedAt.In(loc).Format(time.RFC3339),
+				strconv.Itoa(workout.ID),
+				workout.Name,
+				strconv.Itoa(exercise.Order),
+				optionalInt(exercise.Block),
+				exercise.Exercise.Name,
+				string(exercise.Exercise.Muscle),
+				string(exercise.Exercise.Measurement),
+				strconv.Itoa(set),
+				optionalInt(exercise.Reps),
+				optionalFloat(exercise.Weights.In(units.Weight)),
+				optionalInt(exercise.Duration),
+				optionalFloat(exercise.Distance.In(units.Distance)),
+				optionalInt(exercise.ActualReps),
+				optionalFloat(exercise.ActualWeights.In(units.Weight)),
+				optionalInt(exercise.ActualDuration),
+				optionalFloat(exercise.ActualDistance.In(units.Distance)),
+				string(units.Weight),
+				string(units.Distance),
+				strconv.FormatBool(exercise.Done),
+			})
+		}
+	}
+
+	return records
+}
+
+// optionalInt formats n leaving zero values empty.
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(n)
+}
+
+// optionalFloat formats n leaving zero values empty.
+func optionalFloat(n float64) string {
+	if n == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
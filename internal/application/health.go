@@ -0,0 +1,82 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// readinessTimeout bounds each dependency check of the readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// healthzHandler reports the process is alive, it doesn't check any
+// dependency so an outage of one doesn't get every instance restarted.
+func (app *Application) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "ok"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// readyzHandler reports whether the instance can serve requests: Postgres
+// and Redis are reachable and the database schema is at the version the
+// binary expects. It fails while shutting down.
+func (app *Application) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.ready.Load() {
+		err := app.writeJSON(w, http.StatusServiceUnavailable, envelope{"status": "shutting_down"}, nil)
+		if err != nil {
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	checks := map[string]func(context.Context) error{
+		"database":   app.models.PingDB,
+		"redis":      app.models.PingRedis,
+		"migrations": app.migrator.Check,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	status, results := "ready", map[string]string{}
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			err := check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				log.Warn().Err(err).Str("check", name).Msg("readiness check failed")
+				status, results[name] = "unavailable", "failed"
+				return
+			}
+
+			results[name] = "ok"
+		}()
+	}
+
+	wg.Wait()
+
+	code := http.StatusOK
+	if status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+
+	err := app.writeJSON(w, code, envelope{"status": status, "checks": results}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
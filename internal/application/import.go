@@ -0,0 +1,305 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/importer"
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxImportSize = 10 << 20 // 10 MB
+
+// createImportHandler imports workout history from a CSV file exported by
+// another app. Exercise names are matched to the catalog, names that can't
+// be matched are reported back so the user maps them manually unless
+// skip_unmatched is set. The sessions are stored in the background while
+// the client polls the returned import job.
+func (app *Application) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		BadRequestResponse(w, r, fmt.Errorf("body must be a multipart form of at most %d bytes", maxImportSize))
+		return
+	}
+
+	v := validator.New()
+
+	opts := importer.Options{
+		Units:    user.Units,
+		Location: user.Location(),
+	}
+
+	if s := r.FormValue("source"); s != "" {
+		source, err := importer.GetSource(s)
+		v.Check(err == nil, "source", "must be strong, hevy or fitnotes")
+		opts.Source = source
+	}
+
+	if s := r.FormValue("weight_unit"); s != "" {
+		unit, err := model.GetWeightUnit(s)
+		v.Check(err == nil, "weight_unit", "invalid weight unit")
+		opts.Units.Weight = unit
+	}
+
+	if s := r.FormValue("distance_unit"); s != "" {
+		unit, err := model.GetDistanceUnit(s)
+		v.Check(err == nil, "distance_unit", "invalid distance unit")
+		opts.Units.Distance = unit
+	}
+
+	// names of the file mapped to exercise ids by the user.
+	mapping := map[string]int{}
+	if s := r.FormValue("mapping"); s != "" {
+		err := json.Unmarshal([]byte(s), &mapping)
+		v.Check(err == nil, "mapping", "must be a JSON object of names to exercise ids")
+	}
+
+	skipUnmatched := r.FormValue("skip_unmatched") == "true"
+
+	file, _, err := r.FormFile("file")
+	v.Check(err == nil, "file", "must be provided")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	source, sessions, err := importer.Parse(file, opts)
+	if err != nil {
+		v.AddError("file", err.Error())
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	catalog, _, err := app.models.Exercises.Search(r.Context(), "", "", allRecords)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	byID := make(map[int]*model.Exercise, len(catalog))
+	for _, exercise := range catalog {
+		byID[exercise.ID] = exercise
+	}
+
+	type suggestion struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	type unmatchedName struct {
+		Name        string       `json:"name"`
+		Suggestions []suggestion `json:"suggestions"`
+	}
+
+	matcher := importer.NewMatcher(catalog)
+	matched := map[string]*model.Exercise{}
+	unmatched := []unmatchedName{}
+
+	for _, session := range sessions {
+		for _, set := range session.Sets {
+			name := set.Exercise
+			if _, ok := matched[name]; ok {
+				continue
+			}
+
+			if id, ok := mapping[name]; ok {
+				exercise, ok := byID[id]
+				v.Check(ok, "mapping", fmt.Sprintf("exercise %d of %q doesn't exist", id, name))
+				matched[name] = exercise
+				continue
+			}
+
+			matched[name] = matcher.Match(name)
+
+			if matched[name] == nil {
+				u := unmatchedName{Name: name, Suggestions: []suggestion{}}
+				for _, exercise := range matcher.Suggest(name, 3) {
+					u.Suggestions = append(u.Suggestions, suggestion{exercise.ID, exercise.Name})
+				}
+
+				unmatched = append(unmatched, u)
+			}
+		}
+	}
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if len(unmatched) > 0 && !skipUnmatched {
+		message := "some exercises could not be matched, map them using the mapping field or set skip_unmatched"
+		ErrorResponse(w, r, http.StatusUnprocessableEntity, CodeUnmatchedExercises, message, envelope{"unmatched": unmatched})
+		return
+	}
+
+	exercises := make(map[string]*model.Exercise, len(matched))
+	for name, exercise := range matched {
+		if exercise != nil {
+			exercises[name] = exercise
+		}
+	}
+
+	job := &model.ImportJob{
+		UserID: user.ID,
+		Source: string(source),
+		Status: model.ImportPending,
+		Total:  len(sessions),
+	}
+
+	for _, u := range unmatched {
+		job.AddError(fmt.Sprintf("skipped %q, no matching exercise", u.Name))
+	}
+
+	if err := app.models.Imports.Create(r.Context(), job); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/imports/%d", job.ID))
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"import": job}, headers)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	// started after responding since the job is updated as it runs.
+	// the import outlives the request
+	ctx := context.WithoutCancel(r.Context())
+
+	app.background(func() {
+		app.runImport(ctx, job, sessions, exercises)
+	})
+}
+
+// runImport stores the sessions as workouts of the job's user, saving the
+// progress of the job as it goes.
+func (app *Application) runImport(ctx context.Context, job *model.ImportJob, sessions []*importer.Session, exercises map[string]*model.Exercise) {
+	logger := log.With().Int("import_id", job.ID).Logger()
+
+	ctx, span := tracer.Start(ctx, "import.run", trace.WithAttributes(attribute.Int("import.id", job.ID)))
+	defer span.End()
+
+	save := func() {
+		if err := app.models.Imports.Update(ctx, job); err != nil {
+			logger.Error().Err(err).Msg("can't save import progress")
+		}
+	}
+
+	job.Status = model.ImportRunning
+	save()
+
+	for i, session := range sessions {
+		workout, errs := session.Workout(job.UserID, exercises)
+		for _, err := range errs {
+			job.AddError(err)
+		}
+
+		v := validator.New()
+		if len(workout.Exercises) > 0 {
+			workout.Validate(v)
+		}
+
+		switch {
+		case len(workout.Exercises) == 0:
+			job.Skipped++
+		case !v.Valid():
+			job.Skipped++
+			for key, messages := range v.Errors {
+				for _, message := range messages {
+					job.AddError(fmt.Sprintf("%s on %s: %s %s", session.Name, session.Date.Format("2006-01-02"), key, message))
+				}
+			}
+		default:
+			if err := app.models.Workouts.Create(ctx, workout); err != nil {
+				logger.Error().Err(err).Msg("can't import workout")
+				job.Skipped++
+				job.AddError(fmt.Sprintf("%s on %s: can't be saved", session.Name, session.Date.Format("2006-01-02")))
+				continue
+			}
+			job.Imported++
+		}
+
+		if (i+1)%25 == 0 {
+			save()
+		}
+	}
+
+	job.Status = model.ImportCompleted
+	if job.Imported == 0 && job.Total > 0 {
+		job.Status = model.ImportFailed
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+
+	save()
+}
+
+func (app *Application) getAllImportsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	jobs, err := app.models.Imports.GetAll(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"imports": jobs}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	job, err := app.models.Imports.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": job}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,244 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+	"github.com/rs/zerolog/log"
+)
+
+// interval of the keep-alive comments sent on idle live sessions so proxies
+// don't drop the connection
+const liveKeepAlive = 15 * time.Second
+
+// liveSessionHandler streams the events of the user's session of a workout
+// as server-sent events, starting with a snapshot of the workout. Every
+// client of the user following the same workout gets the same events.
+func (app *Application) liveSessionHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workout.SetUnits(user.Units)
+
+	// subscribe before taking the snapshot so no update is missed in
+	// between.
+	events, err := app.models.Live.Subscribe(r.Context(), user.ID, workout.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	snapshot, err := json.Marshal(workout)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	// the session outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	logger := log.With().Int("user_id", user.ID).Int("workout_id", workout.ID).Logger()
+
+	err = writeLiveEvent(w, &model.LiveEvent{
+		Type:      "snapshot",
+		WorkoutID: workout.ID,
+		Data:      snapshot,
+		SentAt:    time.Now(),
+	})
+	if err == nil {
+		err = rc.Flush()
+	}
+
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+
+	for err == nil {
+		select {
+		case e, ok := <-events:
+			if !ok { // client disconnected
+				return
+			}
+			err = writeLiveEvent(w, e)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-app.shutdown.Done():
+			// clients reconnect to another instance
+			logger.Debug().Msg("live session ended by shutdown")
+			return
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+	}
+
+	logger.Debug().Err(err).Msg("live session ended")
+}
+
+// writeLiveEvent writes the event in the server-sent events format.
+func writeLiveEvent(w http.ResponseWriter, e *model.LiveEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+
+	return err
+}
+
+// publishLiveEventHandler pushes a set completion or a rest timer start to
+// every client following the user's session of the workout.
+func (app *Application) publishLiveEventHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Type     string          `json:"type"`
+		Data     json.RawMessage `json:"data"`
+		ClientID string          `json:"client_id"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	event := &model.LiveEvent{
+		Type:      input.Type,
+		WorkoutID: workout.ID,
+		Data:      input.Data,
+		ClientID:  input.ClientID,
+	}
+
+	v := validator.New()
+	event.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Live.Publish(r.Context(), user.ID, event); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"event": event}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// publishWorkoutUpdate pushes the saved workout to the clients following
+// the user's session of it. Failing to publish is logged and doesn't fail
+// the update.
+func (app *Application) publishWorkoutUpdate(ctx context.Context, user *model.User, workout *model.Workout) {
+	data, err := json.Marshal(workout)
+	if err == nil {
+		err = app.models.Live.Publish(ctx, user.ID, &model.LiveEvent{
+			Type:      model.LiveWorkoutUpdated,
+			WorkoutID: workout.ID,
+			Data:      data,
+		})
+	}
+
+	if err != nil {
+		log.Error().Err(err).Int("workout_id", workout.ID).Msg("can't publish workout update")
+	}
+}
+
+// getHeartRatesHandler returns the heart rate samples wearables recorded
+// during the workout.
+func (app *Application) getHeartRatesHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	heartRates, err := app.models.HeartRates.GetAll(r.Context(), user.ID, int(id))
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"heart_rates": heartRates}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// registerMetrics registers the metrics read from the models when scraped,
+// the database pool statistics and the number of sessions.
+func registerMetrics(models *model.Model) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(models.DB, "jasad"))
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "jasad",
+		Name:      "active_sessions",
+		Help:      "Sessions of signed in users.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		count, err := models.Tokens.CountSessions(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("can't count sessions")
+			return math.NaN()
+		}
+
+		return float64(count)
+	}))
+}
+
+var promHandler = promhttp.Handler()
+
+// metricsHandler serves the metrics to Prometheus, the scrape has to carry
+// the metrics token as a bearer token. The metrics aren't served when no
+// token is configured.
+func (app *Application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if app.cfg.MetricsToken == "" {
+		NotFoundResponse(w, r)
+		return
+	}
+
+	token := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+app.cfg.MetricsToken)) != 1 {
+		AuthenticationErrorResponse(w, r)
+		return
+	}
+
+	promHandler.ServeHTTP(w, r)
+}
@@ -2,38 +2,46 @@ package application
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"net"
+	"math"
 	"net/http"
+	"net/netip"
+	"regexp"
 	"slices"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ahmadabdelrazik/jasad/internal/metrics"
 	"github.com/ahmadabdelrazik/jasad/internal/model"
-	"golang.org/x/time/rate"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/felixge/httpsnoop"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (app *Application) IsAuthorized(next http.HandlerFunc, accpetedRoles ...model.Role) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// get the token
-		cookie, err := r.Cookie("id")
-		if err != nil { // the only possible error is http.ErrNoCookie
-			AuthenticationErrorResponse(w, r)
-			return
-		}
+		// the rate limiter may have looked the session up already
+		session, ok := getSession(r)
+		if !ok {
+			var err error
 
-		token := cookie.Value
-		// get the corresponding session
-		session, err := app.models.Tokens.GetSessionFromToken(token)
-		if err != nil {
-			switch {
-			case errors.Is(err, model.ErrNotFound):
-				AuthenticationErrorResponse(w, r)
-			default:
-				ServerErrorResponse(w, r, err)
+			session, err = app.sessionFromCookie(r)
+			if err != nil {
+				switch {
+				case errors.Is(err, model.ErrNotFound):
+					AuthenticationErrorResponse(w, r)
+				default:
+					ServerErrorResponse(w, r, err)
+				}
+				return
 			}
-			return
 		}
 
 		accpetedRoles = append(accpetedRoles, model.RoleAdmin)
@@ -45,9 +53,15 @@ func (app *Application) IsAuthorized(next http.HandlerFunc, accpetedRoles ...mod
 		}
 
 		// load the full user from database
-		user, err := app.models.Users.GetByID(session.UserID)
+		user, err := app.models.Users.GetByID(r.Context(), session.UserID)
 		if err != nil {
-			ServerErrorResponse(w, r, err)
+			switch {
+			case errors.Is(err, model.ErrNotFound): // deleted account
+				AuthenticationErrorResponse(w, r)
+			default:
+				ServerErrorResponse(w, r, err)
+			}
+			return
 		}
 
 		// place it in the request to be fetched by the handlers
@@ -57,6 +71,37 @@ func (app *Application) IsAuthorized(next http.HandlerFunc, accpetedRoles ...mod
 	}
 }
 
+// sessionFromCookie returns the session of the id cookie, ErrNotFound if
+// there's no cookie or the session expired.
+func (app *Application) sessionFromCookie(r *http.Request) (*model.Session, error) {
+	cookie, err := r.Cookie("id")
+	if err != nil { // the only possible error is http.ErrNoCookie
+		return nil, model.ErrNotFound
+	}
+
+	return app.models.Tokens.GetSessionFromToken(r.Context(), cookie.Value)
+}
+
+// sameSite is the SameSite attribute of the session cookie by its setting.
+var sameSite = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// sessionCookie returns the id cookie holding the session token.
+func (app *Application) sessionCookie(token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     "id",
+		Value:    token,
+		Domain:   app.cfg.CookieDomain,
+		Expires:  expires,
+		Secure:   app.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite[app.cfg.CookieSameSite],
+	}
+}
+
 func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -71,73 +116,283 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-func (app *Application) rateLimit(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
+// rateLimit takes a request from the bucket of the client for the route,
+// routes without a policy of their own share the default one. Clients are
+// the signed in user, or the address the request came from otherwise, and
+// buckets are kept in Redis so limits hold across replicas. Requests are
+// let through when Redis can't be reached.
+func (app *Application) rateLimit(next *router) http.Handler {
+	for pattern := range app.cfg.LimiterRoutes {
+		if !slices.Contains(next.patterns, pattern) {
+			log.Warn().Str("route", pattern).Msg("rate limit policy of a route that doesn't exist")
+		}
 	}
 
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := next.Handler(r)
 
-	go func() {
-		for {
-			time.Sleep(time.Minute)
+		// probes come often from the same address
+		if !app.cfg.LimiterEnable || pattern == "GET /healthz" || pattern == "GET /readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-			mu.Lock()
+		bucket, policy := pattern, app.cfg.LimiterRoutes[pattern]
+		if policy.RPS == 0 {
+			bucket, policy = "default", config.LimiterPolicy{RPS: app.cfg.LimiterRPS, Burst: app.cfg.LimiterBurst}
+		}
 
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
+		client := "ip:" + app.clientIP(r).String()
 
-			mu.Unlock()
+		session, err := app.sessionFromCookie(r)
+		if err == nil {
+			client = "user:" + strconv.Itoa(session.UserID)
+			r = withSession(r, session)
 		}
-	}()
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !app.cfg.LimiterEnable {
+		limit, err := app.models.RateLimits.Allow(r.Context(), bucket+":"+client, policy.RPS, policy.Burst)
+		if err != nil {
+			logError(r, err)
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			ServerErrorResponse(w, r, err)
+		// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(limit.ResetAfter)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Burst, ceilSeconds(time.Duration(float64(policy.Burst)/policy.RPS*float64(time.Second)))))
+
+		if !limit.Allowed {
+			if pattern != "" {
+				setRoute(r, pattern)
+			} else {
+				pattern = "unmatched"
+			}
+
+			metrics.RateLimited.WithLabelValues(pattern).Inc()
+
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(limit.RetryAfter)))
+			RateLimitExceededResponse(w, r)
 			return
 		}
 
-		mu.Lock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds rounds d up to whole seconds, headers don't take fractions.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
 
-		if _, found := clients[ip]; !found {
-			clients[ip] = &client{limiter: rate.NewLimiter(
-				rate.Limit(app.cfg.LimiterRPS),
-				app.cfg.LimiterBurst,
-			)}
+// clientIP returns the address of the client. Behind trusted proxies it's
+// the last address of X-Forwarded-For that wasn't added by one of them, the
+// earlier ones are set by the client and can't be trusted.
+func (app *Application) clientIP(r *http.Request) netip.Addr {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	ip := addr.Addr().Unmap()
+	if !app.isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+
+		ip = hop.Unmap()
+		if !app.isTrustedProxy(ip) {
+			break
 		}
+	}
 
-		clients[ip].lastSeen = time.Now()
+	return ip
+}
 
-		if !clients[ip].limiter.Allow() {
-			mu.Unlock()
-			RateLimitExceededResponse(w, r)
-			return
+func (app *Application) isTrustedProxy(ip netip.Addr) bool {
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTrustedProxies parses the addresses and CIDR ranges of the trusted
+// proxies.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
 		}
 
-		mu.Unlock()
+		if ip, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
 
-		next.ServeHTTP(w, r)
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, must be an address or a CIDR range", proxy)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// requestIDRX matches the request ids accepted from clients and proxies,
+// others are replaced so they can't inject anything into logs.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID tags the request with the X-Request-ID it came with, or a new
+// one, and echoes it in the response so errors can be traced back to it.
+func (app *Application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContext, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+
+	return hex.EncodeToString(bytes)
+}
+
+// requestInfo is filled in while the request is handled, for the access
+// log written once it's served.
+type requestInfo struct {
+	route  string
+	userID int
+}
+
+// accessLog writes a line per request with its outcome and records it in
+// the request metrics. Requests that matched no route or use a non-standard
+// method are grouped together so scanners can't blow up the number of
+// series.
+func (app *Application) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoContext, info))
+
+		m := httpsnoop.CaptureMetrics(next, w, r)
+
+		route := info.route
+		if route == "" {
+			route = "unmatched"
+		}
+
+		method := metricsMethod(r.Method)
+
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(m.Code)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(m.Duration.Seconds())
+
+		id, _ := getRequestID(r)
+
+		event := log.Info().
+			Str("request_id", id).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Str("route", info.route).
+			Int("status", m.Code).
+			Dur("latency", m.Duration).
+			Int64("bytes", m.Written).
+			Str("client_ip", app.clientIP(r).String())
+
+		if info.userID != 0 {
+			event = event.Int("user_id", info.userID)
+		}
+
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			event = event.Str("trace_id", sc.TraceID().String())
+		}
+
+		event.Msg("request")
+	})
+}
+
+// metricsMethod returns the method label of the request metrics, "other"
+// for methods outside the standard ones.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// setRoute records the pattern the request matched, the span of the request
+// is named after it.
+func setRoute(r *http.Request, pattern string) {
+	if info, ok := r.Context().Value(requestInfoContext).(*requestInfo); ok {
+		info.route = pattern
+	}
+
+	span := trace.SpanFromContext(r.Context())
+	span.SetName(pattern)
+	span.SetAttributes(semconv.HTTPRoute(pattern))
+
+	if id, ok := getRequestID(r); ok {
+		span.SetAttributes(attribute.String("http.request_id", id))
+	}
+}
+
 type contextkey string
 
-const userContext contextkey = "user"
+const (
+	userContext        contextkey = "user"
+	sessionContext     contextkey = "session"
+	requestIDContext   contextkey = "request_id"
+	requestInfoContext contextkey = "request_info"
+)
+
+func getRequestID(r *http.Request) (string, bool) {
+	id, ok := r.Context().Value(requestIDContext).(string)
+	return id, ok
+}
+
+func withSession(r *http.Request, session *model.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContext, session)
+	return r.WithContext(ctx)
+}
+
+func getSession(r *http.Request) (*model.Session, bool) {
+	session, ok := r.Context().Value(sessionContext).(*model.Session)
+	return session, ok
+}
 
 func withUser(r *http.Request, user *model.User) *http.Request {
+	if info, ok := r.Context().Value(requestInfoContext).(*requestInfo); ok {
+		info.userID = user.ID
+	}
+
+	trace.SpanFromContext(r.Context()).SetAttributes(semconv.EnduserID(strconv.Itoa(user.ID)))
+
 	ctx := context.WithValue(r.Context(), userContext, user)
 	return r.WithContext(ctx)
 }
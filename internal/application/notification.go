@@ -0,0 +1,182 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+func (app *Application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	preferences, err := app.models.Notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"preferences": preferences}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// updateNotificationPreferencesHandler opts the user in or out of reminders
+// on each channel.
+func (app *Application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	preferences, err := app.models.Notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		EmailReminders   *bool   `json:"email_reminders"`
+		WebhookReminders *bool   `json:"webhook_reminders"`
+		WebhookURL       *string `json:"webhook_url"`
+		RemindBefore     *int    `json:"remind_before"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.EmailReminders != nil {
+		preferences.EmailReminders = *input.EmailReminders
+	}
+	if input.WebhookReminders != nil {
+		preferences.WebhookReminders = *input.WebhookReminders
+	}
+	if input.WebhookURL != nil {
+		preferences.WebhookURL = *input.WebhookURL
+	}
+	if input.RemindBefore != nil {
+		preferences.RemindBefore = *input.RemindBefore
+	}
+
+	v := validator.New()
+	preferences.Validate(v)
+
+	if _, ok := app.channels[model.ChannelEmail]; preferences.EmailReminders && !ok {
+		v.AddError("email_reminders", "email is not available on this server")
+	}
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Notifications.UpdatePreferences(r.Context(), preferences); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "preferences": preferences},
+		nil,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// getAllNotificationsHandler returns the latest notifications of the user
+// along with their delivery status.
+func (app *Application) getAllNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	notifications, err := app.models.Notifications.GetAll(r.Context(), user.ID, 50)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"notifications": notifications}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// testNotificationHandler queues a test notification on every channel the
+// user opted in to, to check that they receive them.
+func (app *Application) testNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	preferences, err := app.models.Notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	addresses := map[model.NotificationChannel]string{}
+	if preferences.EmailReminders {
+		addresses[model.ChannelEmail] = user.Email
+	}
+	if preferences.WebhookReminders {
+		addresses[model.ChannelWebhook] = preferences.WebhookURL
+	}
+
+	if len(addresses) == 0 {
+		v := validator.New()
+		v.AddError("preferences", "no notification channel is enabled")
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	jobs := []*model.NotificationJob{}
+
+	for channel, address := range addresses {
+		job := &model.NotificationJob{
+			UserID:  user.ID,
+			Channel: channel,
+			Address: address,
+			Subject: "Test notification",
+			Body:    "Notifications from Jasad reach you on this channel.",
+			Data:    map[string]any{"event": "notification.test"},
+		}
+
+		if _, err := app.models.Notifications.Enqueue(r.Context(), job); err != nil {
+			ServerErrorResponse(w, r, err)
+			return
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"notifications": jobs}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,300 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/internal/notify"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// how often reminders are queued and due notifications are sent
+	notifierInterval = time.Minute
+
+	// time a claimed notification is locked for while being sent
+	notificationLease = time.Minute
+
+	// notifications claimed at a time
+	notificationBatch = 20
+
+	// all day workouts are reminded of at this time on their date
+	allDayReminderTime = "08:00"
+)
+
+// newChannels returns the notification channels available with the
+// configuration.
+func newChannels(cfg config.Config) map[model.NotificationChannel]notify.Channel {
+	// the webhook channel only reaches public addresses like webhooks do
+	channels := map[model.NotificationChannel]notify.Channel{
+		model.ChannelWebhook: notify.NewWebhook(),
+	}
+
+	if cfg.SMTPHost != "" {
+		channels[model.ChannelEmail] = &notify.SMTP{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			Sender:   cfg.SMTPSender,
+		}
+	}
+
+	return channels
+}
+
+// runNotifier queues reminders of planned workouts and sends due
+// notifications until ctx is done.
+func (app *Application) runNotifier(ctx context.Context) {
+	ticker := time.NewTicker(notifierInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := app.queueReminders(ctx, time.Now()); err != nil {
+			log.Error().Err(err).Msg("can't queue reminders")
+		}
+
+		if err := app.sendNotifications(ctx); err != nil {
+			log.Error().Err(err).Msg("can't send notifications")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueReminders queues the reminders of the planned workouts that are due
+// before the next run. Reminders are deduplicated so each workout is only
+// reminded of once per channel.
+func (app *Application) queueReminders(ctx context.Context, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "notifications.queue_reminders")
+	defer span.End()
+
+	subscribers, err := app.models.Notifications.Subscribers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscribers {
+		loc := model.Profile{TimeZone: s.TimeZone}.Location()
+		today := model.NewDate(now.In(loc))
+
+		occurrences, err := app.models.Schedules.Occurrences(ctx, s.UserID, today, today.AddDays(1), loc)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range occurrences {
+			if o.Status != model.OccurrencePlanned {
+				continue
+			}
+
+			var startsAt, remindAt time.Time
+			if o.StartsAt != nil {
+				startsAt = *o.StartsAt
+				remindAt = startsAt.Add(-time.Duration(s.Preferences.RemindBefore) * time.Minute)
+			} else {
+				// all day occurrences last until midnight of the user
+				startsAt = o.Date.AddDays(1).At("00:00", loc)
+				remindAt = o.Date.At(allDayReminderTime, loc)
+			}
+
+			if remindAt.After(now.Add(notifierInterval)) || !startsAt.After(now) {
+				continue
+			}
+
+			workout, err := app.models.Workouts.GetWorkoutByID(ctx, s.UserID, o.WorkoutID)
+			if err != nil {
+				return err
+			}
+
+			workout.SetUnits(s.Units)
+
+			for _, job := range reminderJobs(s, o, workout, remindAt) {
+				// channels missing from the configuration can't deliver
+				// it, e.g. email without an SMTP server.
+				if _, ok := app.channels[job.Channel]; !ok {
+					continue
+				}
+
+				if _, err := app.models.Notifications.Enqueue(ctx, job); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// reminderJobs returns the reminder of the occurrence on every channel the
+// subscriber opted in to.
+func reminderJobs(s *model.Subscriber, o *model.Occurrence, workout *model.Workout, remindAt time.Time) []*model.NotificationJob {
+	subject := "Reminder: " + o.WorkoutName + " today"
+	if o.Time != "" {
+		subject = fmt.Sprintf("Reminder: %s at %s", o.WorkoutName, o.Time)
+	}
+
+	lines := []string{fmt.Sprintf("You planned %s for %s.", o.WorkoutName, o.Date), ""}
+	for _, exercise := range workout.Exercises {
+		lines = append(lines, "- "+exercise.String())
+	}
+
+	data := map[string]any{
+		"event":        "workout.reminder",
+		"schedule_id":  o.ScheduleID,
+		"workout_id":   o.WorkoutID,
+		"workout_name": o.WorkoutName,
+		"date":         o.Date,
+	}
+	if o.StartsAt != nil {
+		data["starts_at"] = o.StartsAt
+	}
+
+	addresses := map[model.NotificationChannel]string{}
+	if s.Preferences.EmailReminders {
+		addresses[model.ChannelEmail] = s.Email
+	}
+	if s.Preferences.WebhookReminders {
+		addresses[model.ChannelWebhook] = s.Preferences.WebhookURL
+	}
+
+	jobs := []*model.NotificationJob{}
+
+	for channel, address := range addresses {
+		jobs = append(jobs, &model.NotificationJob{
+			UserID:    s.UserID,
+			Channel:   channel,
+			Address:   address,
+			Subject:   subject,
+			Body:      strings.Join(lines, "\n"),
+			Data:      data,
+			DedupeKey: fmt.Sprintf("reminder:%d:%s:%s", o.ScheduleID, o.Date, channel),
+			RunAt:     remindAt,
+		})
+	}
+
+	return jobs
+}
+
+// sendNotifications sends the due notifications in batches until none are
+// left or ctx is done.
+func (app *Application) sendNotifications(ctx context.Context) error {
+	for ctx.Err() == nil {
+		jobs, err := app.models.Notifications.Claim(ctx, notificationBatch, notificationLease)
+		if err != nil {
+			return err
+		}
+
+		for _, job := range jobs {
+			app.sendNotification(ctx, job)
+		}
+
+		if len(jobs) < notificationBatch {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (app *Application) sendNotification(ctx context.Context, job *model.NotificationJob) {
+	logger := log.With().Int64("notification_id", job.ID).Str("channel", string(job.Channel)).Logger()
+
+	ctx, span := tracer.Start(ctx, "notification.send", trace.WithAttributes(
+		attribute.Int64("notification.id", job.ID),
+		attribute.String("notification.channel", string(job.Channel)),
+	))
+	defer span.End()
+
+	address, enabled, err := app.currentAddress(ctx, job)
+	if err == nil && !enabled {
+		logger.Info().Msg("channel disabled, cancelling notification")
+
+		if err := app.models.Notifications.MarkCancelled(context.WithoutCancel(ctx), job); err != nil {
+			logger.Error().Err(err).Msg("can't mark notification as cancelled")
+		}
+		return
+	}
+
+	if err == nil {
+		err = app.deliver(ctx, job, address)
+	}
+
+	// the outcome is recorded even when shutting down
+	ctx = context.WithoutCancel(ctx)
+
+	if err == nil {
+		if err := app.models.Notifications.MarkSent(ctx, job); err != nil {
+			logger.Error().Err(err).Msg("can't mark notification as sent")
+		}
+		return
+	}
+
+	logger.Warn().Err(err).AnErr("cause", errors.Unwrap(err)).Int("attempt", job.Attempts).Msg("failed to send notification")
+	span.SetStatus(codes.Error, err.Error())
+
+	if err := app.models.Notifications.MarkFailed(ctx, job, err, time.Now().Add(backoff(job.Attempts))); err != nil {
+		logger.Error().Err(err).Msg("can't mark notification as failed")
+	}
+}
+
+// deliver sends the job to the address over its channel.
+func (app *Application) deliver(ctx context.Context, job *model.NotificationJob, address string) error {
+	channel, ok := app.channels[job.Channel]
+	if !ok {
+		return errors.New("channel is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationLease/2)
+	defer cancel()
+
+	event, _ := job.Data["event"].(string)
+
+	return channel.Send(ctx, address, notify.Message{
+		Event:   event,
+		Subject: job.Subject,
+		Body:    job.Body,
+		Data:    job.Data,
+		SentAt:  time.Now(),
+	})
+}
+
+// currentAddress returns where the job is to be sent according to the
+// user's current preferences, which may have changed since it was queued,
+// and whether the user still wants notifications on its channel.
+func (app *Application) currentAddress(ctx context.Context, job *model.NotificationJob) (string, bool, error) {
+	preferences, err := app.models.Notifications.GetPreferences(ctx, job.UserID)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch job.Channel {
+	case model.ChannelEmail:
+		return job.Address, preferences.EmailReminders, nil
+	case model.ChannelWebhook:
+		return preferences.WebhookURL, preferences.WebhookReminders, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// backoff returns the delay before retrying after the given number of
+// attempts, doubling from 30 seconds up to an hour with some jitter.
+func backoff(attempts int) time.Duration {
+	delay := min(30*time.Second<<max(attempts-1, 0), time.Hour)
+
+	return delay + rand.N(delay/10+1)
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ahmadabdelrazik/jasad/api"
+)
+
+// TestOpenAPICoversRoutes fails when a route is added without documenting
+// it, or when the document describes a route that doesn't exist.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.Unmarshal(api.Spec, &spec); err != nil {
+		t.Fatalf("parsing the OpenAPI document: %v", err)
+	}
+
+	routed := map[string]bool{}
+
+	for _, pattern := range (&Application{}).routes().patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method", pattern)
+			continue
+		}
+
+		routed[method+" "+path] = true
+
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is missing from api/openapi.json", pattern)
+		}
+	}
+
+	methods := []string{
+		http.MethodGet, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete,
+	}
+
+	for path, operations := range spec.Paths {
+		for _, method := range methods {
+			if _, ok := operations[strings.ToLower(method)]; ok && !routed[method+" "+path] {
+				t.Errorf("api/openapi.json documents %s %s which isn't routed", method, path)
+			}
+		}
+	}
+}
@@ -4,10 +4,80 @@ import (
 	"net/http"
 
 	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func (app *Application) Routes() http.Handler {
-	mux := http.NewServeMux()
+	handler := app.rateLimit(app.routes())
+	handler = app.recoverPanic(handler)
+	handler = app.accessLog(handler)
+	handler = otelhttp.NewHandler(handler, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+
+	return app.requestID(handler)
+}
+
+// router is a ServeMux that remembers the patterns registered on it, the
+// OpenAPI document is checked against them. Requests are tagged with the
+// pattern they matched for logs and traces.
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (rt *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		setRoute(r, pattern)
+		handler(w, r)
+	})
+}
+
+// ServeHTTP answers requests that match no route with problem documents
+// instead of the plain text errors of ServeMux.
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := rt.Handler(r)
+	if pattern != "" {
+		rt.ServeMux.ServeHTTP(w, r)
+		return
+	}
+
+	// ServeMux lists the methods of routes matching the path in the Allow
+	// header of its method not allowed response.
+	hw := &headerWriter{header: http.Header{}}
+	h.ServeHTTP(hw, r)
+
+	if hw.status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", hw.header.Get("Allow"))
+		MethodNotAllowedResponse(w, r)
+		return
+	}
+
+	NotFoundResponse(w, r)
+}
+
+// headerWriter keeps the status and headers of a response and drops its
+// body.
+type headerWriter struct {
+	header http.Header
+	status int
+}
+
+func (hw *headerWriter) Header() http.Header         { return hw.header }
+func (hw *headerWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (hw *headerWriter) WriteHeader(status int)      { hw.status = status }
+
+func (app *Application) routes() *router {
+	mux := &router{ServeMux: http.NewServeMux()}
+
+	mux.HandleFunc("GET /v1/openapi.json", app.openAPIHandler)
+	mux.HandleFunc("GET /v1/docs", app.docsHandler)
+	mux.HandleFunc("GET /metrics", app.metricsHandler)
+	mux.HandleFunc("GET /healthz", app.healthzHandler)
+	mux.HandleFunc("GET /readyz", app.readyzHandler)
 
 	mux.HandleFunc("POST /v1/exercises", app.IsAuthorized(app.createExerciseHandler))
 	mux.HandleFunc("GET /v1/exercises", app.searchExercisesHandler)
@@ -20,12 +90,68 @@ func (app *Application) Routes() http.Handler {
 
 	mux.HandleFunc("GET /v1/users", app.IsAuthorized(app.GetAllUsers))
 	mux.HandleFunc("GET /v1/users/{id}", app.IsAuthorized(app.getUserByIDHandler, model.RoleUser))
+	mux.HandleFunc("PATCH /v1/users/{id}", app.IsAuthorized(app.updateUserHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/users/{id}", app.IsAuthorized(app.deleteUserHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/users/{id}/export", app.IsAuthorized(app.exportHandler, model.RoleUser))
 
 	mux.HandleFunc("POST /v1/workouts", app.IsAuthorized(app.createWorkoutHandler, model.RoleUser))
 	mux.HandleFunc("GET /v1/workouts", app.IsAuthorized(app.getAllWorkoutsHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/workouts/export", app.IsAuthorized(app.exportWorkoutsHandler, model.RoleUser))
 	mux.HandleFunc("GET /v1/workouts/{id}", app.IsAuthorized(app.getWorkoutHandler, model.RoleUser))
 	mux.HandleFunc("PUT /v1/workouts/{id}", app.IsAuthorized(app.updateWorkoutHandler, model.RoleUser))
 	mux.HandleFunc("DELETE /v1/workouts/{id}", app.IsAuthorized(app.deleteWorkoutHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/workouts/{id}/live", app.IsAuthorized(app.liveSessionHandler, model.RoleUser))
+	mux.HandleFunc("POST /v1/workouts/{id}/live/events", app.IsAuthorized(app.publishLiveEventHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/workouts/{id}/heart-rates", app.IsAuthorized(app.getHeartRatesHandler, model.RoleUser))
+	mux.HandleFunc("POST /v1/workouts/import/{token}", app.IsAuthorized(app.importSharedWorkoutHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/imports", app.IsAuthorized(app.createImportHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/imports", app.IsAuthorized(app.getAllImportsHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/imports/{id}", app.IsAuthorized(app.getImportHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/schedules", app.IsAuthorized(app.createScheduleHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/schedules", app.IsAuthorized(app.getAllSchedulesHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/schedules/{id}", app.IsAuthorized(app.getScheduleHandler, model.RoleUser))
+	mux.HandleFunc("PATCH /v1/schedules/{id}", app.IsAuthorized(app.updateScheduleHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/schedules/{id}", app.IsAuthorized(app.deleteScheduleHandler, model.RoleUser))
+	mux.HandleFunc("PUT /v1/schedules/{id}/occurrences/{date}", app.IsAuthorized(app.setOccurrenceStatusHandler, model.RoleUser))
+
+	mux.HandleFunc("GET /v1/calendar", app.IsAuthorized(app.calendarHandler, model.RoleUser))
+	mux.HandleFunc("POST /v1/calendar/feed", app.IsAuthorized(app.createCalendarFeedHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/calendar/feed", app.IsAuthorized(app.deleteCalendarFeedHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/calendar/feed/{token}", app.calendarFeedHandler)
+
+	mux.HandleFunc("GET /v1/notifications", app.IsAuthorized(app.getAllNotificationsHandler, model.RoleUser))
+	mux.HandleFunc("POST /v1/notifications/test", app.IsAuthorized(app.testNotificationHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/notifications/preferences", app.IsAuthorized(app.getNotificationPreferencesHandler, model.RoleUser))
+	mux.HandleFunc("PATCH /v1/notifications/preferences", app.IsAuthorized(app.updateNotificationPreferencesHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/webhooks", app.IsAuthorized(app.createWebhookHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/webhooks", app.IsAuthorized(app.getAllWebhooksHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/webhooks/{id}", app.IsAuthorized(app.getWebhookHandler, model.RoleUser))
+	mux.HandleFunc("PATCH /v1/webhooks/{id}", app.IsAuthorized(app.updateWebhookHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/webhooks/{id}", app.IsAuthorized(app.deleteWebhookHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/webhooks/{id}/deliveries", app.IsAuthorized(app.getWebhookDeliveriesHandler, model.RoleUser))
+	mux.HandleFunc("POST /v1/webhooks/{id}/deliveries/{delivery}/replay", app.IsAuthorized(app.replayWebhookDeliveryHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/measurements", app.IsAuthorized(app.createMeasurementHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/measurements", app.IsAuthorized(app.getAllMeasurementsHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/measurements/trend", app.IsAuthorized(app.measurementTrendHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/measurements/{id}", app.IsAuthorized(app.getMeasurementHandler, model.RoleUser))
+	mux.HandleFunc("PATCH /v1/measurements/{id}", app.IsAuthorized(app.updateMeasurementHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/measurements/{id}", app.IsAuthorized(app.deleteMeasurementHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/templates", app.IsAuthorized(app.createTemplateHandler))
+	mux.HandleFunc("GET /v1/templates", app.searchTemplatesHandler)
+	mux.HandleFunc("GET /v1/templates/{id}", app.getTemplateHandler)
+	mux.HandleFunc("PATCH /v1/templates/{id}", app.IsAuthorized(app.updateTemplateHandler))
+	mux.HandleFunc("DELETE /v1/templates/{id}", app.IsAuthorized(app.deleteTemplateHandler))
+	mux.HandleFunc("POST /v1/templates/{id}/clone", app.IsAuthorized(app.cloneTemplateHandler, model.RoleUser))
+
+	mux.HandleFunc("POST /v1/shares", app.IsAuthorized(app.createShareHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/shares", app.IsAuthorized(app.getAllSharesHandler, model.RoleUser))
+	mux.HandleFunc("DELETE /v1/shares/{id}", app.IsAuthorized(app.revokeShareHandler, model.RoleUser))
+	mux.HandleFunc("GET /v1/shared/{token}", app.getSharedWorkoutHandler)
 
-	return app.recoverPanic(app.rateLimit(mux))
+	return mux
 }
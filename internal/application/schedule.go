@@ -0,0 +1,539 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/ical"
+	"github.com/ahmadabdelrazik/jasad/pkg/rrule"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// InputSchedule holds the fields of a schedule sent by the client, fields
+// left out keep their current value.
+type InputSchedule struct {
+	WorkoutID *int        `json:"workout_id"`
+	StartDate *model.Date `json:"start_date"`
+	Time      *string     `json:"time"`
+	RRule     *string     `json:"rrule"`
+}
+
+func (input InputSchedule) apply(s *model.Schedule) {
+	if input.WorkoutID != nil {
+		s.WorkoutID = *input.WorkoutID
+	}
+	if input.StartDate != nil {
+		s.StartDate = *input.StartDate
+	}
+	if input.Time != nil {
+		s.Time = *input.Time
+	}
+	if input.RRule != nil {
+		s.RRule = *input.RRule
+	}
+}
+
+// validateSchedule validates the schedule and makes sure its workout belongs
+// to the user. The recurrence rule is normalized to its RFC 5545 form.
+func (app *Application) validateSchedule(ctx context.Context, v *validator.Validator, s *model.Schedule) error {
+	s.Validate(v)
+	if !v.Valid() {
+		return nil
+	}
+
+	if s.RRule != "" {
+		rule, _ := rrule.Parse(s.RRule)
+		s.RRule = rule.String()
+	}
+
+	workout, err := app.models.Workouts.GetWorkoutByID(ctx, s.UserID, s.WorkoutID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			v.AddError("workout_id", "workout doesn't exist")
+			return nil
+		default:
+			return err
+		}
+	}
+
+	s.WorkoutName = workout.Name
+
+	return nil
+}
+
+func (app *Application) createScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input InputSchedule
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	schedule := &model.Schedule{UserID: user.ID}
+	input.apply(schedule)
+
+	v := validator.New()
+	if err := app.validateSchedule(r.Context(), v, schedule); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Schedules.Create(r.Context(), schedule); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"schedule": schedule}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getAllSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	schedules, err := app.models.Schedules.GetAll(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"schedules": schedules}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.models.Schedules.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"schedule": schedule}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.models.Schedules.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input InputSchedule
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	input.apply(schedule)
+
+	v := validator.New()
+	if err := app.validateSchedule(r.Context(), v, schedule); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Schedules.Update(r.Context(), schedule); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "schedule": schedule},
+		nil,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Schedules.Delete(r.Context(), user.ID, int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "deleted successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// setOccurrenceStatusHandler marks a planned workout of a schedule as
+// completed or skipped.
+func (app *Application) setOccurrenceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	date, err := model.ParseDate(r.PathValue("date"))
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.models.Schedules.Get(r.Context(), user.ID, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !schedule.OccursOn(date) {
+		NotFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	status, err := model.GetOccurrenceStatus(input.Status)
+	v.Check(err == nil, "status", "must be planned, completed or skipped")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Schedules.SetStatus(r.Context(), schedule.ID, date, status); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if status == model.OccurrenceCompleted {
+		app.events.Emit(r.Context(), model.EventSessionCompleted, user.ID, model.SessionCompleted{
+			WorkoutID:   schedule.WorkoutID,
+			WorkoutName: schedule.WorkoutName,
+			ScheduleID:  &schedule.ID,
+			Date:        date,
+		})
+	}
+
+	occurrences, err := app.models.Schedules.Occurrences(r.Context(), user.ID, date, date, user.Location())
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	var occurrence *model.Occurrence
+	for _, o := range occurrences {
+		if o.ScheduleID == schedule.ID {
+			occurrence = o
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"occurrence": occurrence}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// calendarHandler returns the planned workouts of the user within a date
+// range, 30 days starting today in the user's time zone by default.
+func (app *Application) calendarHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	loc := user.Location()
+	today := model.NewDate(time.Now().In(loc))
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	from := model.NewDate(app.readDate(qs, "from", today.Time, v))
+	to := model.NewDate(app.readDate(qs, "to", from.AddDays(30).Time, v))
+
+	v.Check(!to.Before(from.Time), "to", "must not be before from")
+	v.Check(!to.After(from.AddDays(366).Time), "to", "must be within a year of from")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	occurrences, err := app.models.Schedules.Occurrences(r.Context(), user.ID, from, to, loc)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"from":        from,
+		"to":          to,
+		"time_zone":   loc.String(),
+		"occurrences": occurrences,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// createCalendarFeedHandler returns a secret URL to subscribe to the user's
+// calendar from calendar apps. Creating a new feed revokes the previous one.
+func (app *Application) createCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	token, err := app.models.Schedules.CreateFeed(r.Context(), user.ID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"url": "/v1/calendar/feed/" + token}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Schedules.DeleteFeed(r.Context(), user.ID); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "calendar feed revoked"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// calendarFeedHandler serves the planned workouts of the feed's owner from
+// 3 months ago up to a year ahead as an iCalendar document. It's
+// authenticated by the token in the URL since calendar apps can't log in.
+func (app *Application) calendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.models.Schedules.GetFeedOwner(r.Context(), r.PathValue("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	today := model.NewDate(time.Now().In(user.Location()))
+
+	app.writeCalendar(w, r, user, "Jasad", "", today.AddDays(-90), today.AddDays(365))
+}
+
+// writeCalendar writes the user's planned workouts within [from, to] as an
+// iCalendar document named name, as an attachment if filename is set.
+func (app *Application) writeCalendar(w http.ResponseWriter, r *http.Request, user *model.User, name, filename string, from, to model.Date) {
+	occurrences, err := app.models.Schedules.Occurrences(r.Context(), user.ID, from, to, user.Location())
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	// the workouts are needed for their exercises and durations.
+	workouts := map[int]*model.Workout{}
+	for _, o := range occurrences {
+		if _, ok := workouts[o.WorkoutID]; ok {
+			continue
+		}
+
+		workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, o.WorkoutID)
+		if err != nil {
+			ServerErrorResponse(w, r, err)
+			return
+		}
+
+		workout.SetUnits(user.Units)
+		workouts[o.WorkoutID] = workout
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if filename != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+
+	cal := ical.NewWriter(w, name)
+
+	for _, o := range occurrences {
+		if err := cal.WriteEvent(occurrenceEvent(o, workouts[o.WorkoutID])); err != nil {
+			logError(r, err)
+			return
+		}
+	}
+
+	if err := cal.Close(); err != nil {
+		logError(r, err)
+	}
+}
+
+// occurrenceEvent returns the calendar event of a planned workout, all day
+// if the schedule has no time of day.
+func occurrenceEvent(o *model.Occurrence, workout *model.Workout) ical.Event {
+	lines := make([]string, len(workout.Exercises))
+	for i, exercise := range workout.Exercises {
+		lines[i] = exercise.String()
+	}
+
+	event := ical.Event{
+		UID:         fmt.Sprintf("schedule-%d-%s@jasad", o.ScheduleID, o.Date),
+		Summary:     o.WorkoutName,
+		Description: strings.Join(lines, "\n"),
+		Status:      "CONFIRMED",
+	}
+
+	if o.Status == model.OccurrenceSkipped {
+		event.Status = "CANCELLED"
+	}
+
+	if o.StartsAt != nil {
+		event.Start = *o.StartsAt
+		event.End = o.StartsAt.Add(workout.EstimatedDuration())
+	} else {
+		event.AllDay = true
+		event.Start = o.Date.Time
+		event.End = o.Date.AddDays(1).Time
+	}
+
+	return event
+}
@@ -0,0 +1,206 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+func (app *Application) createShareHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		WorkoutID int        `json:"workout_id"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	// make sure the workout belongs to the user
+	workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, input.WorkoutID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	share := &model.WorkoutShare{
+		WorkoutID: workout.ID,
+		OwnerID:   user.ID,
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	v := validator.New()
+	share.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Shares.Create(r.Context(), share); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"share": share}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getAllSharesHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	workoutID := app.readInt(r.URL.Query(), "workout_id", 0, v)
+	v.Check(workoutID >= 0, "workout_id", "must be a positive number")
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	shares, err := app.models.Shares.GetAll(r.Context(), user.ID, workoutID)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shares": shares}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) revokeShareHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Shares.Revoke(r.Context(), user.ID, int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "share revoked successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// getSharedWorkoutHandler is a read-only view of a shared workout. It doesn't
+// require authentication, holding the token is enough.
+func (app *Application) getSharedWorkoutHandler(w http.ResponseWriter, r *http.Request) {
+	workout, err := app.getSharedWorkout(r.Context(), r.PathValue("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"workout": workout}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// importSharedWorkoutHandler copies a shared workout into the user's
+// account.
+func (app *Application) importSharedWorkoutHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	shared, err := app.getSharedWorkout(r.Context(), r.PathValue("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workout := shared.Copy(user.ID)
+
+	v := validator.New()
+	workout.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Workouts.Create(r.Context(), workout); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	workout.SetUnits(user.Units)
+
+	app.events.Emit(r.Context(), model.EventWorkoutCreated, user.ID, workout)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"workout": workout}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// getSharedWorkout returns the workout shared by the given token.
+func (app *Application) getSharedWorkout(ctx context.Context, token string) (*model.Workout, error) {
+	if token == "" {
+		return nil, model.ErrNotFound
+	}
+
+	share, err := app.models.Shares.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.models.Workouts.GetWorkoutByID(ctx, share.OwnerID, share.WorkoutID)
+}
@@ -0,0 +1,372 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+func (app *Application) createTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Goal        string          `json:"goal"`
+		Level       string          `json:"level"`
+		Exercises   []InputExercise `json:"exercises"`
+		Blocks      []InputBlock    `json:"blocks"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	goal, err := model.GetGoal(input.Goal)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	level, err := model.GetLevel(input.Level)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	workoutExercises, err := getWorkoutExercises(r.Context(), app.models, input.Exercises, user.Profile)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrExerciseLimitReached):
+			v := validator.New()
+			v.AddError("exercises", "must be less than 20 exercise")
+			FailedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workoutBlocks, err := getWorkoutBlocks(input.Blocks)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	template := &model.WorkoutTemplate{
+		Description: input.Description,
+		Goal:        goal,
+		Level:       level,
+		Workout: &model.Workout{
+			Name:              input.Name,
+			Exercises:         workoutExercises,
+			Blocks:            workoutBlocks,
+			NumberOfExercises: len(workoutExercises),
+		},
+	}
+
+	v := validator.New()
+	template.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Templates.Create(r.Context(), template); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	template.Workout.SetUnits(user.Units)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"template": template}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) searchTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string `json:"name"`
+		Goal   string `json:"goal"`
+		Level  string `json:"level"`
+		Muscle string `json:"muscle"`
+		model.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Goal = app.readString(qs, "goal", "")
+	input.Level = app.readString(qs, "level", "")
+	input.Muscle = app.readString(qs, "muscle", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+
+	input.Filters.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	if input.Goal != "" {
+		_, err := model.GetGoal(input.Goal)
+		v.Check(err == nil, "goal", "invalid goal")
+	}
+	if input.Level != "" {
+		_, err := model.GetLevel(input.Level)
+		v.Check(err == nil, "level", "invalid level")
+	}
+	if input.Muscle != "" {
+		_, err := model.GetMuscle(input.Muscle)
+		v.Check(err == nil, "muscle", "invalid muscle name")
+	}
+
+	model.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	templates, metadata, err := app.models.Templates.Search(r.Context(),
+		input.Name,
+		input.Goal,
+		input.Level,
+		input.Muscle,
+		input.Filters,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"templates": templates, "metadata": metadata}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	template, err := app.models.Templates.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"template": template}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	template, err := app.models.Templates.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name        *string         `json:"name"`
+		Description *string         `json:"description"`
+		Goal        *string         `json:"goal"`
+		Level       *string         `json:"level"`
+		Exercises   []InputExercise `json:"exercises"`
+		Blocks      []InputBlock    `json:"blocks"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		template.Workout.Name = *input.Name
+	}
+	if input.Description != nil {
+		template.Description = *input.Description
+	}
+	if input.Goal != nil {
+		goal, err := model.GetGoal(*input.Goal)
+		if err != nil {
+			BadRequestResponse(w, r, err)
+			return
+		}
+		template.Goal = goal
+	}
+	if input.Level != nil {
+		level, err := model.GetLevel(*input.Level)
+		if err != nil {
+			BadRequestResponse(w, r, err)
+			return
+		}
+		template.Level = level
+	}
+	if input.Exercises != nil {
+		workoutExercises, err := getWorkoutExercises(r.Context(), app.models, input.Exercises, user.Profile)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrExerciseLimitReached):
+				v := validator.New()
+				v.AddError("exercises", "must be less than 20 exercise")
+				FailedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, model.ErrNotFound):
+				NotFoundResponse(w, r)
+			default:
+				ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		template.Workout.Exercises = workoutExercises
+		template.Workout.NumberOfExercises = len(workoutExercises)
+	}
+	if input.Blocks != nil {
+		workoutBlocks, err := getWorkoutBlocks(input.Blocks)
+		if err != nil {
+			BadRequestResponse(w, r, err)
+			return
+		}
+
+		template.Workout.Blocks = workoutBlocks
+	}
+
+	v := validator.New()
+	template.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Templates.Update(r.Context(), template); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	template.Workout.SetUnits(user.Units)
+
+	err = app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "template": template},
+		nil,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Templates.Delete(r.Context(), int(id)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "template deleted successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// cloneTemplateHandler copies the template's workout into the user's
+// workouts.
+func (app *Application) cloneTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	template, err := app.models.Templates.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workout := template.Workout.Copy(user.ID)
+
+	if err := app.models.Workouts.Create(r.Context(), workout); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	workout.SetUnits(user.Units)
+
+	app.events.Emit(r.Context(), model.EventWorkoutCreated, user.ID, workout)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"workout": workout}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
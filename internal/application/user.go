@@ -3,8 +3,10 @@ package application
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
 )
 
 func (app *Application) getUserByIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,7 +26,7 @@ func (app *Application) getUserByIDHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.models.Users.GetByID(int(id))
+	user, err := app.models.Users.GetByID(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
@@ -35,26 +37,136 @@ func (app *Application) getUserByIDHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	output := struct {
-		ID    int    `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-		Role  string `json:"role"`
-	}{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  string(user.Role),
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": newUserOutput(user)}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
 	}
+}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": output}, nil)
+// updateUserHandler edits the profile of the user. Clients can send the
+// version they last read in the X-Expected-Version header to avoid
+// overwriting changes made in the meantime.
+func (app *Application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	authUser, ok := getUser(r)
+	if !ok { // if there is no user in context
+		UnauthorizedResponse(w, r)
+		return
+		// if the user is not changing his info and isn't an admin.
+	} else if authUser.ID != int(id) && authUser.Role != model.RoleAdmin {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetByID(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if expected := r.Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.Itoa(user.Version) != expected {
+			EditConflictResponse(w, r)
+			return
+		}
+	}
+
+	var input struct {
+		DisplayName *string `json:"display_name"`
+		AvatarURL   *string `json:"avatar_url"`
+		BirthYear   *int    `json:"birth_year"`
+		Sex         *string `json:"sex"`
+		Height      *int    `json:"height"`
+		Goal        *string `json:"goal"`
+		Level       *string `json:"level"`
+		TimeZone    *string `json:"time_zone"`
+		Units       *struct {
+			Weight   *string `json:"weight"`
+			Distance *string `json:"distance"`
+		} `json:"units"`
+		DefaultRest *int `json:"default_rest"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.DisplayName != nil {
+		user.DisplayName = *input.DisplayName
+	}
+	if input.AvatarURL != nil {
+		user.AvatarURL = *input.AvatarURL
+	}
+	if input.BirthYear != nil {
+		user.BirthYear = *input.BirthYear
+	}
+	if input.Sex != nil {
+		user.Sex = model.Sex(*input.Sex)
+	}
+	if input.Height != nil {
+		user.Height = *input.Height
+	}
+	if input.Goal != nil {
+		user.Goal = model.Goal(*input.Goal)
+	}
+	if input.Level != nil {
+		user.Level = model.Level(*input.Level)
+	}
+	if input.TimeZone != nil {
+		user.TimeZone = *input.TimeZone
+	}
+	if input.Units != nil && input.Units.Weight != nil {
+		user.Units.Weight = model.WeightUnit(*input.Units.Weight)
+	}
+	if input.Units != nil && input.Units.Distance != nil {
+		user.Units.Distance = model.DistanceUnit(*input.Units.Distance)
+	}
+	if input.DefaultRest != nil {
+		user.DefaultRest = *input.DefaultRest
+	}
+
+	v := validator.New()
+	user.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Users.Update(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "user": newUserOutput(user)},
+		nil,
+	)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 	}
 }
 
 func (app *Application) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := app.models.Users.GetAll()
+	users, err := app.models.Users.GetAll(r.Context())
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 		return
@@ -68,3 +180,25 @@ func (app *Application) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		ServerErrorResponse(w, r, err)
 	}
 }
+
+// userOutput is the user as presented to clients, version is included to
+// be used with X-Expected-Version.
+type userOutput struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	model.Profile
+	Version int `json:"version"`
+}
+
+func newUserOutput(user *model.User) userOutput {
+	return userOutput{
+		ID:      user.ID,
+		Name:    user.Name,
+		Email:   user.Email,
+		Role:    string(user.Role),
+		Profile: user.Profile,
+		Version: user.Version,
+	}
+}
@@ -0,0 +1,285 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// InputWebhook is the body of webhook creation and update requests.
+type InputWebhook struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (input InputWebhook) apply(wh *model.Webhook) {
+	if input.URL != nil {
+		wh.URL = *input.URL
+	}
+	if input.Events != nil {
+		wh.Events = input.Events
+	}
+	if input.Active != nil {
+		wh.Active = *input.Active
+	}
+}
+
+// createWebhookHandler subscribes a url to events. Admins can create global
+// webhooks which receive the events of every user.
+func (app *Application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		InputWebhook
+		Global bool `json:"global"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Global && user.Role != model.RoleAdmin {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	webhook := &model.Webhook{UserID: user.ID, Global: input.Global, Active: true}
+	input.apply(webhook)
+
+	v := validator.New()
+	webhook.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Webhooks.Create(r.Context(), webhook); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook}, headers)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// getAllWebhooksHandler returns the webhooks of the user, admins get the
+// global ones too.
+func (app *Application) getAllWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return
+	}
+
+	webhooks, err := app.models.Webhooks.GetAll(r.Context(), user.ID, user.Role == model.RoleAdmin)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var input InputWebhook
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	input.apply(webhook)
+
+	v := validator.New()
+	webhook.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Webhooks.Update(r.Context(), webhook); err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			EditConflictResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err := app.writeJSON(
+		w,
+		http.StatusOK,
+		envelope{"message": "updated successfully", "webhook": webhook},
+		nil,
+	)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Webhooks.Delete(r.Context(), webhook.ID); err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "deleted successfully"}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// getWebhookDeliveriesHandler returns the delivery log of the webhook, the
+// latest deliveries first.
+func (app *Application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var filters model.Filters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	filters.Sort = "-id"
+	filters.SortSafeList = []string{"-id"}
+
+	model.ValidateFilters(v, filters)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.Deliveries(r.Context(), webhook.ID, filters)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// replayWebhookDeliveryHandler sends a past delivery of the webhook again.
+func (app *Application) replayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(r.PathValue("delivery"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		BadRequestResponse(w, r, errors.New("invalid delivery id parameter"))
+		return
+	}
+
+	delivery, err := app.models.Webhooks.Replay(r.Context(), webhook.ID, deliveryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		ServerErrorResponse(w, r, err)
+	}
+}
+
+// readWebhook returns the webhook in the path if the user can manage it,
+// users manage their own webhooks and admins manage the global ones. It
+// writes the error response otherwise.
+func (app *Application) readWebhook(w http.ResponseWriter, r *http.Request) (*model.Webhook, bool) {
+	// get user id
+	user, ok := getUser(r)
+	if !ok {
+		UnauthorizedResponse(w, r)
+		return nil, false
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	webhook, err := app.models.Webhooks.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			NotFoundResponse(w, r)
+		default:
+			ServerErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if webhook.Global && user.Role != model.RoleAdmin || !webhook.Global && webhook.UserID != user.ID {
+		NotFoundResponse(w, r)
+		return nil, false
+	}
+
+	return webhook, true
+}
@@ -1,25 +1,48 @@
 package application
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"slices"
 
 	"github.com/ahmadabdelrazik/jasad/internal/model"
 	"github.com/ahmadabdelrazik/jasad/pkg/validator"
 )
 
+// InputExercise is a workout entry as sent by the user. Weights and
+// distances are in the user's preferred units unless WeightUnit overrides
+// the weight unit of the entry.
 type InputExercise struct {
 	ExerciseID int     `json:"exercise_id"`
 	Order      int     `json:"order"` // order in the workout
+	Block      int     `json:"block"` // order of the block, 0 if not grouped
 	Sets       int     `json:"sets"`
 	Reps       int     `json:"reps,omitempty"`
-	Weights    float32 `json:"weights,omitempty"`
+	Weights    float64 `json:"weights,omitempty"`
+	Duration   int     `json:"duration,omitempty"` // in seconds
+	Distance   float64 `json:"distance,omitempty"`
 
-	// in seconds
-	RestAfter int  `json:"rest_after,omitempty"`
+	ActualReps     int     `json:"actual_reps,omitempty"`
+	ActualWeights  float64 `json:"actual_weights,omitempty"`
+	ActualDuration int     `json:"actual_duration,omitempty"` // in seconds
+	ActualDistance float64 `json:"actual_distance,omitempty"`
+
+	WeightUnit string `json:"weight_unit,omitempty"`
+
+	// in seconds, the user's default rest is used if not set
+	RestAfter *int `json:"rest_after,omitempty"`
 	Done      bool `json:"done"`
 }
 
+type InputBlock struct {
+	Order             int    `json:"order"` // order in the workout
+	Type              string `json:"type"`
+	Rounds            int    `json:"rounds"`
+	RestBetweenRounds int    `json:"rest_between_rounds"`
+	Duration          int    `json:"duration"`
+}
+
 var ErrExerciseLimitReached = errors.New("exceeded exercises limit")
 
 func (app *Application) createWorkoutHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,6 +56,7 @@ func (app *Application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 	var input struct {
 		Name      string          `json:"name"`
 		Exercises []InputExercise `json:"exercises"`
+		Blocks    []InputBlock    `json:"blocks"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
@@ -40,7 +64,7 @@ func (app *Application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	workoutExercises, err := getWorkoutExercises(app.models, input.Exercises)
+	workoutExercises, err := getWorkoutExercises(r.Context(), app.models, input.Exercises, user.Profile)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrExerciseLimitReached):
@@ -55,10 +79,17 @@ func (app *Application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	workoutBlocks, err := getWorkoutBlocks(input.Blocks)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
 	workout := &model.Workout{
 		OwnerID:           user.ID,
 		Name:              input.Name,
 		Exercises:         workoutExercises,
+		Blocks:            workoutBlocks,
 		NumberOfExercises: len(workoutExercises),
 	}
 
@@ -70,11 +101,15 @@ func (app *Application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.models.Workouts.Create(workout); err != nil {
+	if err := app.models.Workouts.Create(r.Context(), workout); err != nil {
 		ServerErrorResponse(w, r, err)
 		return
 	}
 
+	workout.SetUnits(user.Units)
+
+	app.events.Emit(r.Context(), model.EventWorkoutCreated, user.ID, workout)
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"workout": workout}, nil)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
@@ -89,27 +124,63 @@ func (app *Application) getAllWorkoutsHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	workouts, err := app.models.Workouts.GetAllByID(user.ID)
+	var input struct {
+		Name       string `json:"name"`
+		ExerciseID int    `json:"exercise_id"`
+		Muscle     string `json:"muscle"`
+		model.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.ExerciseID = app.readInt(qs, "exercise_id", 0, v)
+	input.Muscle = app.readString(qs, "muscle", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+
+	input.Filters.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	v.Check(input.ExerciseID >= 0, "exercise_id", "must be a positive number")
+	if input.Muscle != "" {
+		_, err := model.GetMuscle(input.Muscle)
+		v.Check(err == nil, "muscle", "invalid muscle name")
+	}
+
+	model.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	workouts, metadata, err := app.models.Workouts.GetAll(r.Context(),
+		user.ID,
+		input.Name,
+		input.ExerciseID,
+		input.Muscle,
+		input.Filters,
+	)
 	if err != nil {
-		switch {
-		case errors.Is(err, model.ErrNotFound):
-			NotFoundResponse(w, r)
-		default:
-			ServerErrorResponse(w, r, err)
-		}
+		ServerErrorResponse(w, r, err)
 		return
 	}
 
-	v := validator.New()
 	for _, workout := range workouts {
-		workout.Validate(v)
-		if !v.Valid() {
-			FailedValidationResponse(w, r, v.Errors)
-			return
-		}
+		workout.SetUnits(user.Units)
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"workouts": workouts}, nil)
+	if err := app.setBodyweight(r.Context(), user, workouts...); err != nil {
+		ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"workouts": workouts, "metadata": metadata}, nil)
 	if err != nil {
 		ServerErrorResponse(w, r, err)
 	}
@@ -129,7 +200,7 @@ func (app *Application) getWorkoutHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	workout, err := app.models.Workouts.GetWorkoutByID(user.ID, int(id))
+	workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
@@ -140,10 +211,10 @@ func (app *Application) getWorkoutHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	v := validator.New()
-	workout.Validate(v)
-	if !v.Valid() {
-		FailedValidationResponse(w, r, v.Errors)
+	workout.SetUnits(user.Units)
+
+	if err := app.setBodyweight(r.Context(), user, workout); err != nil {
+		ServerErrorResponse(w, r, err)
 		return
 	}
 
@@ -167,7 +238,7 @@ func (app *Application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	workout, err := app.models.Workouts.GetWorkoutByID(user.ID, int(id))
+	workout, err := app.models.Workouts.GetWorkoutByID(r.Context(), user.ID, int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
@@ -181,6 +252,7 @@ func (app *Application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 	var input struct {
 		Name      string          `json:"name"`
 		Exercises []InputExercise `json:"exercises"`
+		Blocks    []InputBlock    `json:"blocks"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
@@ -188,7 +260,7 @@ func (app *Application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	workoutExercises, err := getWorkoutExercises(app.models, input.Exercises)
+	workoutExercises, err := getWorkoutExercises(r.Context(), app.models, input.Exercises, user.Profile)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrExerciseLimitReached):
@@ -203,11 +275,28 @@ func (app *Application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	workoutBlocks, err := getWorkoutBlocks(input.Blocks)
+	if err != nil {
+		BadRequestResponse(w, r, err)
+		return
+	}
+
+	wasDone := workout.Done()
+
 	workout.Name = input.Name
 	workout.Exercises = workoutExercises
+	workout.Blocks = workoutBlocks
 	workout.NumberOfExercises = len(workoutExercises)
 
-	if err := app.models.Workouts.Update(workout); err != nil {
+	v := validator.New()
+	workout.Validate(v)
+
+	if !v.Valid() {
+		FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Workouts.Update(r.Context(), workout); err != nil {
 		switch {
 		case errors.Is(err, model.ErrEditConflict):
 			EditConflictResponse(w, r)
@@ -217,6 +306,14 @@ func (app *Application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	workout.SetUnits(user.Units)
+
+	app.publishWorkoutUpdate(r.Context(), user, workout)
+
+	if !wasDone && workout.Done() {
+		app.events.WorkoutCompleted(r.Context(), user, workout)
+	}
+
 	err = app.writeJSON(
 		w,
 		http.StatusOK,
@@ -242,7 +339,7 @@ func (app *Application) deleteWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.models.Workouts.Delete(user.ID, int(workoutID)); err != nil {
+	if err := app.models.Workouts.Delete(r.Context(), user.ID, int(workoutID)); err != nil {
 		switch {
 		case errors.Is(err, model.ErrNotFound):
 			NotFoundResponse(w, r)
@@ -256,8 +353,10 @@ func (app *Application) deleteWorkoutHandler(w http.ResponseWriter, r *http.Requ
 }
 
 // getWorkoutExercises convert the inputExercises to a WorkoutExercise and
-// populate the exercise field with exercise full details
-func getWorkoutExercises(models *model.Model, inputExercises []InputExercise) ([]model.WorkoutExercise, error) {
+// populate the exercise field with exercise full details. Weights and
+// distances are converted from the units of the profile to their canonical
+// form.
+func getWorkoutExercises(ctx context.Context, models *model.Model, inputExercises []InputExercise, profile model.Profile) ([]model.WorkoutExercise, error) {
 	// get all the exercise IDs from input
 	ids := make([]int, len(inputExercises))
 	for i := range ids {
@@ -270,7 +369,7 @@ func getWorkoutExercises(models *model.Model, inputExercises []InputExercise) ([
 	}
 
 	// fetch all exercises by their IDs
-	exercises, err := models.Exercises.GetByIDs(ids...)
+	exercises, err := models.Exercises.GetByIDs(ctx, ids...)
 	if err != nil {
 		return nil, err
 	}
@@ -278,14 +377,68 @@ func getWorkoutExercises(models *model.Model, inputExercises []InputExercise) ([
 	workoutExercises := make([]model.WorkoutExercise, len(inputExercises))
 
 	for i, we := range inputExercises {
+		weightUnit := profile.Units.Weight
+		if we.WeightUnit != "" {
+			weightUnit = model.WeightUnit(we.WeightUnit)
+		}
+
+		restAfter := profile.DefaultRest
+		if we.RestAfter != nil {
+			restAfter = *we.RestAfter
+		}
+
 		workoutExercises[i].Order = we.Order
+		workoutExercises[i].Block = we.Block
 		workoutExercises[i].Sets = we.Sets
 		workoutExercises[i].Reps = we.Reps
-		workoutExercises[i].Weights = we.Weights
-		workoutExercises[i].RestAfter = we.RestAfter
+		workoutExercises[i].Weights = model.NewWeight(we.Weights, weightUnit)
+		workoutExercises[i].Duration = we.Duration
+		workoutExercises[i].Distance = model.NewDistance(we.Distance, profile.Units.Distance)
+		workoutExercises[i].ActualReps = we.ActualReps
+		workoutExercises[i].ActualWeights = model.NewWeight(we.ActualWeights, weightUnit)
+		workoutExercises[i].ActualDuration = we.ActualDuration
+		workoutExercises[i].ActualDistance = model.NewDistance(we.ActualDistance, profile.Units.Distance)
+		workoutExercises[i].WeightUnit = model.WeightUnit(we.WeightUnit)
+		workoutExercises[i].RestAfter = restAfter
 		workoutExercises[i].Done = we.Done
 		workoutExercises[i].Exercise = exercises[i]
 	}
 
+	// keep exercises sorted by their order in the workout regardless of the
+	// order they were sent in.
+	slices.SortStableFunc(workoutExercises, func(a, b model.WorkoutExercise) int {
+		return a.Order - b.Order
+	})
+
 	return workoutExercises, nil
 }
+
+// getWorkoutBlocks convert the inputBlocks to WorkoutBlock. Rounds defaults
+// to a single round.
+func getWorkoutBlocks(inputBlocks []InputBlock) ([]model.WorkoutBlock, error) {
+	workoutBlocks := make([]model.WorkoutBlock, len(inputBlocks))
+
+	for i, b := range inputBlocks {
+		blockType, err := model.GetBlockType(b.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		rounds := b.Rounds
+		if rounds == 0 {
+			rounds = 1
+		}
+
+		workoutBlocks[i].Order = b.Order
+		workoutBlocks[i].Type = blockType
+		workoutBlocks[i].Rounds = rounds
+		workoutBlocks[i].RestBetweenRounds = b.RestBetweenRounds
+		workoutBlocks[i].Duration = b.Duration
+	}
+
+	slices.SortStableFunc(workoutBlocks, func(a, b model.WorkoutBlock) int {
+		return a.Order - b.Order
+	})
+
+	return workoutBlocks, nil
+}
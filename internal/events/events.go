@@ -0,0 +1,91 @@
+// Package events queues the events webhooks subscribe to, so they are
+// emitted the same way whether the change came through the HTTP API or
+// from a device.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/metrics"
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/rs/zerolog/log"
+)
+
+type Emitter struct {
+	models *model.Model
+}
+
+func New(models *model.Model) *Emitter {
+	return &Emitter{models: models}
+}
+
+// Emit queues the delivery of the event to the webhooks subscribed to it.
+// userID is the user the event belongs to, 0 for catalog events. Failing to
+// queue is logged and doesn't fail the change that caused the event.
+func (em *Emitter) Emit(ctx context.Context, event string, userID int, data any) {
+	metrics.Events.WithLabelValues(event).Inc()
+
+	payload, err := json.Marshal(map[string]any{
+		"event":      event,
+		"created_at": time.Now(),
+		"data":       data,
+	})
+	if err == nil {
+		err = em.models.Webhooks.Enqueue(ctx, event, userID, payload)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("event", event).Int("user_id", userID).Msg("can't queue webhook deliveries")
+	}
+}
+
+// WorkoutCompleted emits the completion of the workout, along with the
+// records achieved in it, once all of its exercises are done. The workout
+// is expected to be in the user's units.
+func (em *Emitter) WorkoutCompleted(ctx context.Context, user *model.User, workout *model.Workout) {
+	em.Emit(ctx, model.EventSessionCompleted, user.ID, model.SessionCompleted{
+		WorkoutID:   workout.ID,
+		WorkoutName: workout.Name,
+		Date:        model.NewDate(time.Now().In(user.Location())),
+	})
+
+	exerciseIDs := []int{}
+	for _, exercise := range workout.Exercises {
+		if exercise.ActualWeights > 0 {
+			exerciseIDs = append(exerciseIDs, exercise.Exercise.ID)
+		}
+	}
+
+	if len(exerciseIDs) == 0 {
+		return
+	}
+
+	best, err := em.models.Workouts.BestActualWeights(ctx, user.ID, workout.ID, exerciseIDs)
+	if err != nil {
+		log.Error().Err(err).Int("workout_id", workout.ID).Msg("can't check records")
+		return
+	}
+
+	for _, exercise := range workout.Exercises {
+		if exercise.ActualWeights == 0 || exercise.ActualWeights <= best[exercise.Exercise.ID] {
+			continue
+		}
+
+		unit := exercise.DisplayUnits().Weight
+
+		data := map[string]any{
+			"workout_id":    workout.ID,
+			"exercise_id":   exercise.Exercise.ID,
+			"exercise_name": exercise.Exercise.Name,
+			"weights":       exercise.ActualWeights.In(unit),
+			"weight_unit":   unit,
+		}
+		if previous, ok := best[exercise.Exercise.ID]; ok {
+			data["previous_weights"] = previous.In(unit)
+		}
+
+		em.Emit(ctx, model.EventRecordAchieved, user.ID, data)
+	}
+}
@@ -0,0 +1,166 @@
+package importer
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+)
+
+// minScore is the minimum similarity for a name to be matched to an
+// exercise without asking the user.
+const minScore = 0.85
+
+// abbreviations commonly used in exercise names.
+var abbreviations = map[string]string{
+	"db":  "dumbbell",
+	"bb":  "barbell",
+	"kb":  "kettlebell",
+	"ez":  "ez bar",
+	"ohp": "overhead press",
+	"rdl": "romanian deadlift",
+	"sl":  "single leg",
+}
+
+// Matcher matches exercise names used by other apps to the catalog.
+type Matcher struct {
+	exercises []*model.Exercise
+	names     []normalized
+}
+
+func NewMatcher(exercises []*model.Exercise) *Matcher {
+	names := make([]normalized, len(exercises))
+	for i, exercise := range exercises {
+		names[i] = normalize(exercise.Name)
+	}
+
+	return &Matcher{exercises: exercises, names: names}
+}
+
+// Match returns the exercise most similar to name, or nil if none is
+// similar enough.
+func (m *Matcher) Match(name string) *model.Exercise {
+	suggestions := m.suggest(name, 1)
+	if len(suggestions) == 0 || suggestions[0].score < minScore {
+		return nil
+	}
+
+	return suggestions[0].exercise
+}
+
+// Suggest returns up to n exercises most similar to name, best first.
+func (m *Matcher) Suggest(name string, n int) []*model.Exercise {
+	suggestions := m.suggest(name, n)
+
+	exercises := make([]*model.Exercise, len(suggestions))
+	for i, s := range suggestions {
+		exercises[i] = s.exercise
+	}
+
+	return exercises
+}
+
+type suggestion struct {
+	exercise *model.Exercise
+	score    float64
+}
+
+func (m *Matcher) suggest(name string, n int) []suggestion {
+	target := normalize(name)
+
+	suggestions := make([]suggestion, len(m.exercises))
+	for i, exercise := range m.exercises {
+		suggestions[i] = suggestion{exercise, similarity(target, m.names[i])}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return suggestions[:min(n, len(suggestions))]
+}
+
+// normalized forms of a name, both without spaces so "Push Ups" matches
+// "Pushup".
+type normalized struct {
+	ordered string // words in their original order
+	sorted  string // words sorted, so "Bench Press (Barbell)" matches "Barbell Bench Press"
+}
+
+func normalize(name string) normalized {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	expanded := make([]string, 0, len(words))
+	for _, word := range words {
+		if full, ok := abbreviations[word]; ok {
+			expanded = append(expanded, strings.Fields(full)...)
+			continue
+		}
+
+		// plurals, "curls" is the same exercise as "curl".
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+
+		expanded = append(expanded, word)
+	}
+
+	ordered := strings.Join(expanded, "")
+
+	slices.Sort(expanded)
+
+	return normalized{ordered: ordered, sorted: strings.Join(expanded, "")}
+}
+
+// similarity of two names from 0 to 1.
+func similarity(a, b normalized) float64 {
+	return max(ratio(a.ordered, b.ordered), ratio(a.sorted, b.sorted))
+}
+
+// ratio is 1 minus the edit distance of a and b relative to the longer one.
+func ratio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
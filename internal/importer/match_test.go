@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "squat", b: "squat", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "", b: "abc", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "flaw", b: "lawn", want: 2},
+		{a: "قرفصاء", b: "قرفصا", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "", b: "", want: 0},
+		{a: "squat", b: "squat", want: 1},
+		{a: "deadlift", b: "deadlif", want: 0.875},
+		{a: "deadlift", b: "deadli", want: 0.75},
+		{a: "abc", b: "xyz", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := ratio(tt.a, tt.b); got != tt.want {
+			t.Errorf("ratio(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	catalog := []*model.Exercise{
+		{ID: 1, Name: "Barbell Bench Press"},
+		{ID: 2, Name: "Dumbbell Curl"},
+		{ID: 3, Name: "Push Up"},
+		{ID: 4, Name: "Deadlift"},
+		{ID: 5, Name: "Romanian Deadlift"},
+		{ID: 6, Name: "Cross"},
+	}
+
+	m := NewMatcher(catalog)
+
+	tests := []struct {
+		name string
+		want int // 0 when nothing matches
+	}{
+		{name: "Barbell Bench Press", want: 1},
+		{name: "Bench Press (Barbell)", want: 1},
+		{name: "BB Bench Press", want: 1},
+		{name: "DB Curls", want: 2},
+		{name: "Pushups", want: 3},
+		{name: "push-up", want: 3},
+		{name: "Deadlifts", want: 4},
+		{name: "RDL", want: 5},
+		// one edit in eight letters scores 0.875, above minScore
+		{name: "Deadlif", want: 4},
+		// two edits in eight letters score 0.75, below minScore
+		{name: "Deadli", want: 0},
+		// one edit in six letters scores 0.83, just below minScore
+		{name: "Crosss", want: 0},
+		{name: "Leg Press", want: 0},
+		{name: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		got := 0
+		if exercise := m.Match(tt.name); exercise != nil {
+			got = exercise.ID
+		}
+
+		if got != tt.want {
+			t.Errorf("Match(%q) = exercise %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
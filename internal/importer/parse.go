@@ -0,0 +1,390 @@
+// Package importer reads workout history exported by other fitness apps and
+// converts it into workouts of the catalog exercises.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+)
+
+type Source string
+
+const (
+	SourceStrong   Source = "strong"
+	SourceHevy            = "hevy"
+	SourceFitNotes        = "fitnotes"
+)
+
+func GetSource(s string) (Source, error) {
+	switch s {
+	case "strong":
+		return SourceStrong, nil
+	case "hevy":
+		return SourceHevy, nil
+	case "fitnotes":
+		return SourceFitNotes, nil
+	default:
+		return "", errors.New("invalid source")
+	}
+}
+
+// Set is a single set logged in the other app.
+type Set struct {
+	Exercise string
+	Reps     int
+	Weight   model.Weight
+	Duration int // in seconds
+	Distance model.Distance
+}
+
+// Session is a workout performed on a given date.
+type Session struct {
+	Name string
+	Date time.Time
+	Sets []Set
+}
+
+type Options struct {
+	// Source of the file, detected from its header when empty.
+	Source Source
+
+	// Units of the values in files that don't specify them.
+	Units model.Units
+
+	// Location dates without a time zone are in.
+	Location *time.Location
+}
+
+// columns are the names of the columns holding each value in a source's
+// file. Values with multiple columns use whichever one the file has.
+type columns struct {
+	date, name, exercise    string
+	reps, duration          string
+	kilograms, pounds       string
+	kilometers, miles       string
+	weight, distance        string
+	distanceUnit, setType   string
+	durationIsClock         bool // durations are formatted as h:mm:ss
+	weightsInPreferredUnits bool // weight holds values in Options.Units
+}
+
+var sourceColumns = map[Source]columns{
+	SourceStrong: {
+		date:                    "date",
+		name:                    "workout name",
+		exercise:                "exercise name",
+		reps:                    "reps",
+		duration:                "seconds",
+		weight:                  "weight",
+		distance:                "distance",
+		setType:                 "set order",
+		weightsInPreferredUnits: true,
+	},
+	SourceHevy: {
+		date:       "start_time",
+		name:       "title",
+		exercise:   "exercise_title",
+		reps:       "reps",
+		duration:   "duration_seconds",
+		kilograms:  "weight_kg",
+		pounds:     "weight_lbs",
+		kilometers: "distance_km",
+		miles:      "distance_miles",
+		setType:    "set_type",
+	},
+	SourceFitNotes: {
+		date:            "date",
+		exercise:        "exercise",
+		reps:            "reps",
+		duration:        "time",
+		kilograms:       "weight (kgs)",
+		pounds:          "weight (lbs)",
+		distance:        "distance",
+		distanceUnit:    "distance unit",
+		durationIsClock: true,
+	},
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2 Jan 2006, 15:04",
+	"02 Jan 2006, 15:04",
+	"Jan 2, 2006, 3:04 PM",
+}
+
+// Parse reads the CSV export of another app and groups its sets into
+// sessions ordered by date. It returns the source of the file, which is
+// detected when opts doesn't specify it.
+func Parse(r io.Reader, opts Options) (Source, []*Session, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Units.Weight == "" || opts.Units.Distance == "" {
+		opts.Units = model.DefaultUnits
+	}
+
+	br := bufio.NewReader(r)
+
+	// some apps separate values with semicolons depending on the locale.
+	firstLine, _ := br.Peek(1024)
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	cr := csv.NewReader(br)
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return "", nil, fmt.Errorf("can't read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+
+	source := opts.Source
+	if source == "" {
+		source, err = detectSource(index)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	cols := sourceColumns[source]
+
+	for _, required := range []string{cols.date, cols.exercise} {
+		if _, ok := index[required]; !ok {
+			return "", nil, fmt.Errorf("missing %q column for %s files", required, source)
+		}
+	}
+
+	sessions := []*Session{}
+	byKey := map[string]*Session{}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		value := func(column string) string {
+			i, ok := index[column]
+			if column == "" || !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		// warm up sets aren't part of the workout and rest timers aren't
+		// sets at all.
+		switch strings.ToLower(value(cols.setType)) {
+		case "warmup", "rest timer":
+			continue
+		}
+
+		set, err := parseSet(value, cols, opts.Units)
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// rows without any values
+		if set.Exercise == "" || set == (Set{Exercise: set.Exercise}) {
+			continue
+		}
+
+		rawDate, name := value(cols.date), value(cols.name)
+
+		key := rawDate + "\x00" + name
+		session, ok := byKey[key]
+		if !ok {
+			date, err := parseDate(rawDate, opts.Location)
+			if err != nil {
+				return "", nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			if name == "" {
+				name = "Workout on " + date.Format("2006-01-02")
+			}
+
+			session = &Session{Name: name, Date: date}
+			byKey[key] = session
+			sessions = append(sessions, session)
+		}
+
+		session.Sets = append(session.Sets, set)
+	}
+
+	slices.SortStableFunc(sessions, func(a, b *Session) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	return source, sessions, nil
+}
+
+// detectSource guesses which app exported the file from its columns.
+func detectSource(index map[string]int) (Source, error) {
+	has := func(column string) bool {
+		_, ok := index[column]
+		return ok
+	}
+
+	switch {
+	case has("exercise_title"):
+		return SourceHevy, nil
+	case has("workout name") && has("exercise name"):
+		return SourceStrong, nil
+	case has("exercise") && has("category"):
+		return SourceFitNotes, nil
+	default:
+		return "", errors.New("unknown file format, specify the source")
+	}
+}
+
+func parseSet(value func(string) string, cols columns, units model.Units) (Set, error) {
+	set := Set{Exercise: value(cols.exercise)}
+
+	reps, err := parseNumber(value(cols.reps))
+	if err != nil {
+		return Set{}, fmt.Errorf("invalid reps: %w", err)
+	}
+	set.Reps = int(reps)
+
+	if cols.durationIsClock {
+		set.Duration, err = parseClock(value(cols.duration))
+	} else {
+		var seconds float64
+		seconds, err = parseNumber(value(cols.duration))
+		set.Duration = int(seconds)
+	}
+	if err != nil {
+		return Set{}, fmt.Errorf("invalid duration: %w", err)
+	}
+
+	switch {
+	case value(cols.kilograms) != "":
+		set.Weight, err = parseWeight(value(cols.kilograms), model.Kilograms)
+	case value(cols.pounds) != "":
+		set.Weight, err = parseWeight(value(cols.pounds), model.Pounds)
+	case cols.weightsInPreferredUnits:
+		set.Weight, err = parseWeight(value(cols.weight), units.Weight)
+	}
+	if err != nil {
+		return Set{}, fmt.Errorf("invalid weight: %w", err)
+	}
+
+	switch {
+	case value(cols.kilometers) != "":
+		set.Distance, err = parseDistance(value(cols.kilometers), "km", units)
+	case value(cols.miles) != "":
+		set.Distance, err = parseDistance(value(cols.miles), "mi", units)
+	case value(cols.distance) != "":
+		set.Distance, err = parseDistance(value(cols.distance), value(cols.distanceUnit), units)
+	}
+	if err != nil {
+		return Set{}, fmt.Errorf("invalid distance: %w", err)
+	}
+
+	return set, nil
+}
+
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseNumber parses a decimal number, empty values are zero.
+func parseNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%q is negative", s)
+	}
+
+	return n, nil
+}
+
+// parseClock parses durations formatted as h:mm:ss, mm:ss or seconds.
+func parseClock(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	seconds := 0
+
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a duration", s)
+		}
+		seconds = seconds*60 + n
+	}
+
+	return seconds, nil
+}
+
+func parseWeight(s string, unit model.WeightUnit) (model.Weight, error) {
+	n, err := parseNumber(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return model.NewWeight(n, unit), nil
+}
+
+// parseDistance converts the distance in the given unit to meters. Unknown
+// units fall back to the user's preferred distance unit.
+func parseDistance(s, unit string, units model.Units) (model.Distance, error) {
+	n, err := parseNumber(s)
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.ToLower(unit) {
+	case "m", "meters", "metres":
+		return model.Distance(math.Round(n)), nil
+	case "km", "kilometers", "kilometres":
+		return model.NewDistance(n, model.Kilometers), nil
+	case "mi", "miles":
+		return model.NewDistance(n, model.Miles), nil
+	case "ft", "feet":
+		return model.Distance(math.Round(n * 0.3048)), nil
+	case "yd", "yards":
+		return model.Distance(math.Round(n * 0.9144)), nil
+	default:
+		return model.NewDistance(n, units.Distance), nil
+	}
+}
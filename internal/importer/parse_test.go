@@ -0,0 +1,221 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+)
+
+func TestDetectSource(t *testing.T) {
+	tests := []struct {
+		header  []string
+		want    Source
+		wantErr bool
+	}{
+		{
+			header: []string{"date", "workout name", "duration", "exercise name", "set order", "weight", "reps"},
+			want:   SourceStrong,
+		},
+		{
+			header: []string{"title", "start_time", "end_time", "exercise_title", "set_type", "weight_kg", "reps"},
+			want:   SourceHevy,
+		},
+		{
+			header: []string{"date", "exercise", "category", "weight (kgs)", "reps", "distance", "distance unit", "time"},
+			want:   SourceFitNotes,
+		},
+		{header: []string{"date", "exercise name", "reps"}, wantErr: true},
+		{header: []string{"date", "exercise", "reps"}, wantErr: true},
+		{header: []string{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		index := make(map[string]int, len(tt.header))
+		for i, name := range tt.header {
+			index[name] = i
+		}
+
+		got, err := detectSource(index)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("detectSource(%q) = %q, want an error", tt.header, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("detectSource(%q) returned error: %v", tt.header, err)
+		} else if got != tt.want {
+			t.Errorf("detectSource(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantSource Source
+		want       []*Session
+	}{
+		{
+			name: "strong with semicolons",
+			file: "\ufeffDate;Workout Name;Exercise Name;Set Order;Weight;Reps;Distance;Seconds\n" +
+				"2024-01-03 09:00:00;Legs;Squat (Barbell);1;100;5;0;0\n" +
+				"2024-01-01 10:00:00;Push Day;Bench Press (Barbell);1;60;10;0;0\n" +
+				"2024-01-01 10:00:00;Push Day;Bench Press (Barbell);2;80;5;0;0\n",
+			wantSource: SourceStrong,
+			want: []*Session{
+				{
+					Name: "Push Day",
+					Date: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+					Sets: []Set{
+						{Exercise: "Bench Press (Barbell)", Reps: 10, Weight: model.NewWeight(60, model.Kilograms)},
+						{Exercise: "Bench Press (Barbell)", Reps: 5, Weight: model.NewWeight(80, model.Kilograms)},
+					},
+				},
+				{
+					Name: "Legs",
+					Date: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC),
+					Sets: []Set{
+						{Exercise: "Squat (Barbell)", Reps: 5, Weight: model.NewWeight(100, model.Kilograms)},
+					},
+				},
+			},
+		},
+		{
+			name: "hevy skips warm up sets",
+			file: "title,start_time,end_time,exercise_title,set_index,set_type,weight_kg,reps,distance_km,duration_seconds\n" +
+				`Morning,"1 Jan 2024, 10:00","1 Jan 2024, 11:00",Deadlift (Barbell),0,warmup,60,5,,` + "\n" +
+				`Morning,"1 Jan 2024, 10:00","1 Jan 2024, 11:00",Deadlift (Barbell),1,normal,140,3,,` + "\n" +
+				`Morning,"1 Jan 2024, 10:00","1 Jan 2024, 11:00",Running,0,normal,,,5,1800` + "\n",
+			wantSource: SourceHevy,
+			want: []*Session{
+				{
+					Name: "Morning",
+					Date: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+					Sets: []Set{
+						{Exercise: "Deadlift (Barbell)", Reps: 3, Weight: model.NewWeight(140, model.Kilograms)},
+						{Exercise: "Running", Duration: 1800, Distance: model.NewDistance(5, model.Kilometers)},
+					},
+				},
+			},
+		},
+		{
+			name: "fitnotes names sessions by date",
+			file: "Date,Exercise,Category,Weight (lbs),Reps,Distance,Distance Unit,Time\n" +
+				"2024-01-02,Pull Up,Back,,8,,,\n" +
+				"2024-01-02,Plank,Core,,,,,0:01:30\n" +
+				"2024-01-02,Treadmill,Cardio,,,1,mi,\n" +
+				"2024-01-02,Curl,Arms,,,,,\n",
+			wantSource: SourceFitNotes,
+			want: []*Session{
+				{
+					Name: "Workout on 2024-01-02",
+					Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+					Sets: []Set{
+						{Exercise: "Pull Up", Reps: 8},
+						{Exercise: "Plank", Duration: 90},
+						{Exercise: "Treadmill", Distance: model.NewDistance(1, model.Miles)},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		source, got, err := Parse(strings.NewReader(tt.file), Options{})
+		if err != nil {
+			t.Errorf("%s: Parse returned error: %v", tt.name, err)
+			continue
+		}
+
+		if source != tt.wantSource {
+			t.Errorf("%s: Parse source = %q, want %q", tt.name, source, tt.wantSource)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Parse = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "empty file", file: ""},
+		{name: "unknown format", file: "day,lift,count\n2024-01-01,Squat,5\n"},
+		{name: "invalid reps", file: "Date,Exercise,Category,Reps\n2024-01-01,Squat,Legs,five\n"},
+		{name: "negative weight", file: "Date,Exercise,Category,Weight (kgs),Reps\n2024-01-01,Squat,Legs,-5,5\n"},
+		{name: "invalid date", file: "Date,Exercise,Category,Reps\n01/02/2024,Squat,Legs,5\n"},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := Parse(strings.NewReader(tt.file), Options{}); err == nil {
+			t.Errorf("%s: Parse returned no error", tt.name)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "45", want: 45},
+		{in: "1:30", want: 90},
+		{in: "1:02:03", want: 3723},
+		{in: "1:-2", wantErr: true},
+		{in: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseClock(tt.in)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseClock(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseClock(%q) returned error: %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("parseClock(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDistance(t *testing.T) {
+	units := model.Units{Weight: model.Pounds, Distance: model.Miles}
+
+	tests := []struct {
+		value, unit string
+		want        model.Distance
+	}{
+		{value: "400", unit: "m", want: 400},
+		{value: "5", unit: "km", want: 5000},
+		{value: "1", unit: "Miles", want: model.NewDistance(1, model.Miles)},
+		{value: "100", unit: "yd", want: 91},
+		{value: "2", unit: "", want: model.NewDistance(2, model.Miles)},
+		{value: "", unit: "km", want: 0},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDistance(tt.value, tt.unit, units)
+		if err != nil {
+			t.Errorf("parseDistance(%q, %q) returned error: %v", tt.value, tt.unit, err)
+		} else if got != tt.want {
+			t.Errorf("parseDistance(%q, %q) = %d, want %d", tt.value, tt.unit, got, tt.want)
+		}
+	}
+}
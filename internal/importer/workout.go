@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// Workout converts the session into a completed workout owned by ownerID.
+// exercises maps the names used in the file to catalog exercises, sets of
+// unmapped names are left out. Consecutive sets of the same exercise become
+// a single entry holding the top set. Entries that don't fit the measurement
+// of their exercise are left out as well and reported in the returned
+// errors.
+func (s *Session) Workout(ownerID int, exercises map[string]*model.Exercise) (*model.Workout, []string) {
+	workout := &model.Workout{
+		OwnerID:   ownerID,
+		Name:      truncate(s.Name, 50),
+		CreatedAt: s.Date,
+	}
+
+	var errs []string
+
+	for start := 0; start < len(s.Sets); {
+		end := start + 1
+		for end < len(s.Sets) && s.Sets[end].Exercise == s.Sets[start].Exercise {
+			end++
+		}
+
+		sets := s.Sets[start:end]
+		start = end
+
+		exercise, ok := exercises[sets[0].Exercise]
+		if !ok {
+			continue
+		}
+
+		entry := newEntry(exercise, sets)
+		entry.Order = len(workout.Exercises) + 1
+
+		v := validator.New()
+		entry.Validate(v)
+
+		if !v.Valid() {
+			for _, key := range slices.Sorted(maps.Keys(v.Errors)) {
+				errs = append(errs, fmt.Sprintf("%s on %s: %s: %s %s",
+					s.Name, s.Date.Format("2006-01-02"), sets[0].Exercise, key, strings.Join(v.Errors[key], ", ")))
+			}
+			continue
+		}
+
+		workout.Exercises = append(workout.Exercises, entry)
+	}
+
+	workout.NumberOfExercises = len(workout.Exercises)
+
+	return workout, errs
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	end := 0
+	for i := range s {
+		if i > n {
+			break
+		}
+		end = i
+	}
+
+	return s[:end]
+}
+
+// newEntry returns a done workout entry of the sets using the values tracked
+// by the exercise's measurement.
+func newEntry(exercise *model.Exercise, sets []Set) model.WorkoutExercise {
+	top := sets[0]
+	for _, set := range sets[1:] {
+		if set.Weight > top.Weight || (set.Weight == top.Weight && set.Reps > top.Reps) {
+			top = set
+		}
+	}
+
+	entry := model.WorkoutExercise{
+		Exercise: exercise,
+		Sets:     len(sets),
+		Done:     true,
+	}
+
+	m := exercise.Measurement
+
+	switch m {
+	case model.MeasurementReps:
+		for _, set := range sets {
+			entry.Reps = max(entry.Reps, set.Reps)
+		}
+	case model.MeasurementRepsAndWeight:
+		entry.Reps = top.Reps
+		entry.Weights = top.Weight
+	case model.MeasurementDuration:
+		for _, set := range sets {
+			entry.Duration = max(entry.Duration, set.Duration)
+		}
+	case model.MeasurementDistance, model.MeasurementDistanceAndWeight:
+		for _, set := range sets {
+			entry.Distance = max(entry.Distance, set.Distance)
+		}
+		if m == model.MeasurementDistanceAndWeight {
+			entry.Weights = top.Weight
+		}
+	}
+
+	entry.ActualReps = entry.Reps
+	entry.ActualWeights = entry.Weights
+	entry.ActualDuration = entry.Duration
+	entry.ActualDistance = entry.Distance
+
+	return entry
+}
@@ -0,0 +1,33 @@
+package importer
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{in: "Push Day", n: 50, want: "Push Day"},
+		{in: "abcdef", n: 3, want: "abc"},
+		{in: "abc", n: 3, want: "abc"},
+		{in: "تمرين", n: 10, want: "تمرين"},
+		{in: "تمرين", n: 5, want: "تم"},
+		{in: "تمرين", n: 1, want: ""},
+		{in: "a😀b", n: 4, want: "a"},
+		{in: "a😀b", n: 5, want: "a😀"},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) || len(got) > tt.n {
+			t.Errorf("truncate(%q, %d) = %q, not a valid string of at most %d bytes", tt.in, tt.n, got, tt.n)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package ingest records the events smart gym equipment and wearables
+// report during a workout into the user's session of it.
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/internal/events"
+	"github.com/ahmadabdelrazik/jasad/internal/model"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// TopicFilter matches the topics devices publish the events of a user to,
+// "jasad/users/<user id>/events". The broker is expected to only let the
+// user's devices publish to the user's topic.
+const TopicFilter = "jasad/users/+/events"
+
+// SharedTopicFilter returns the shared subscription to TopicFilter of the
+// group, so each event is handled by a single member of the group. It's
+// TopicFilter itself when group is empty.
+func SharedTopicFilter(group string) string {
+	if group == "" {
+		return TopicFilter
+	}
+
+	return "$share/" + group + "/" + TopicFilter
+}
+
+// UserFromTopic returns the id of the user an event was published for.
+func UserFromTopic(topic string) (int, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "jasad" || parts[1] != "users" || parts[3] != "events" {
+		return 0, fmt.Errorf("unexpected topic %q", topic)
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid user id in topic %q", topic)
+	}
+
+	return id, nil
+}
+
+// Types of events devices report.
+const (
+	EventSet       = "set"
+	EventRep       = "rep"
+	EventHeartRate = "heart_rate"
+)
+
+// Event is reported by a device during a workout of the user.
+type Event struct {
+	Type      string `json:"type"`
+	WorkoutID int    `json:"workout_id"`
+
+	// EntryID is the id of the exercise entry of the workout the set or rep
+	// belongs to.
+	EntryID int `json:"entry_id,omitempty"`
+
+	// Set is the number of the completed set, starting from 1. The entry is
+	// done once its last set is completed.
+	Set          int     `json:"set,omitempty"`
+	Reps         int     `json:"reps,omitempty"`
+	Weight       float64 `json:"weight,omitempty"`
+	WeightUnit   string  `json:"weight_unit,omitempty"`
+	Duration     int     `json:"duration,omitempty"` // in seconds
+	Distance     float64 `json:"distance,omitempty"`
+	DistanceUnit string  `json:"distance_unit,omitempty"`
+
+	BPM int `json:"bpm,omitempty"`
+
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func (e Event) Validate(v *validator.Validator) {
+	v.Check(slices.Contains([]string{EventSet, EventRep, EventHeartRate}, e.Type), "type", "must be set, rep or heart_rate")
+	v.Check(e.WorkoutID > 0, "workout_id", "must be provided")
+
+	switch e.Type {
+	case EventSet:
+		v.Check(e.EntryID > 0, "entry_id", "must be provided")
+		v.Check(e.Set > 0, "set", "must be provided")
+		v.Check(e.Set <= 20, "set", "must not be more than 20")
+		v.Check(e.Reps >= 0, "reps", "must be a positive number")
+		v.Check(e.Weight >= 0, "weight", "must be a positive number")
+		v.Check(e.Duration >= 0, "duration", "must be a positive number")
+		v.Check(e.Distance >= 0, "distance", "must be a positive number")
+	case EventRep:
+		v.Check(e.EntryID > 0, "entry_id", "must be provided")
+		v.Check(e.Reps > 0, "reps", "must be provided")
+	case EventHeartRate:
+		model.HeartRate{BPM: e.BPM, RecordedAt: e.RecordedAt}.Validate(v)
+	}
+
+	if e.WeightUnit != "" {
+		_, err := model.GetWeightUnit(e.WeightUnit)
+		v.Check(err == nil, "weight_unit", "must be kg or lb")
+	}
+	if e.DistanceUnit != "" {
+		_, err := model.GetDistanceUnit(e.DistanceUnit)
+		v.Check(err == nil, "distance_unit", "must be km or mi")
+	}
+}
+
+// ValidationError is returned for events that fail validation.
+type ValidationError map[string][]string
+
+func (err ValidationError) Error() string {
+	fields := []string{}
+	for key, messages := range err {
+		for _, message := range messages {
+			fields = append(fields, key+" "+message)
+		}
+	}
+
+	slices.Sort(fields)
+
+	return "invalid event: " + strings.Join(fields, ", ")
+}
+
+// times a set is retried when the workout is edited concurrently
+const maxConflictRetries = 3
+
+// Ingester records reported events using the same models as the HTTP API
+// and pushes them to the clients following the session live.
+type Ingester struct {
+	models *model.Model
+	events *events.Emitter
+}
+
+func New(models *model.Model) *Ingester {
+	return &Ingester{models: models, events: events.New(models)}
+}
+
+// Handle records the event the payload holds in the user's session.
+// Workouts that don't exist or belong to other users are reported as
+// model.ErrNotFound.
+func (in *Ingester) Handle(ctx context.Context, userID int, payload []byte) error {
+	var e Event
+
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	if e.RecordedAt.IsZero() {
+		e.RecordedAt = time.Now()
+	}
+
+	v := validator.New()
+	e.Validate(v)
+
+	if !v.Valid() {
+		return ValidationError(v.Errors)
+	}
+
+	switch e.Type {
+	case EventSet:
+		return in.recordSet(ctx, userID, &e)
+	case EventRep:
+		return in.countRep(ctx, userID, &e)
+	default:
+		return in.recordHeartRate(ctx, userID, &e)
+	}
+}
+
+// recordSet saves the set into the workout entry, keeping the heaviest set
+// as the performed one, and marks the entry as done after its last set.
+// Sets the entry can't hold, like a duration for a weights exercise, are
+// reported as ValidationError.
+func (in *Ingester) recordSet(ctx context.Context, userID int, e *Event) error {
+	user, err := in.models.Users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var workout *model.Workout
+	var wasDone bool
+
+	for attempt := 1; ; attempt++ {
+		workout, err = in.models.Workouts.GetWorkoutByID(ctx, userID, e.WorkoutID)
+		if err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(workout.Exercises, func(entry model.WorkoutExercise) bool {
+			return entry.ID == e.EntryID
+		})
+		if i == -1 {
+			return model.ErrNotFound
+		}
+
+		wasDone = workout.Done()
+
+		applySet(&workout.Exercises[i], e, user.Units)
+
+		v := validator.New()
+		workout.Validate(v)
+
+		if !v.Valid() {
+			return ValidationError(v.Errors)
+		}
+
+		err = in.models.Workouts.Update(ctx, workout)
+		if !errors.Is(err, model.ErrEditConflict) || attempt == maxConflictRetries {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	workout.SetUnits(user.Units)
+
+	// the set is saved, the webhooks are told about the completion even if
+	// the clients following the session miss it.
+	if !wasDone && workout.Done() {
+		in.events.WorkoutCompleted(ctx, user, workout)
+	}
+
+	if err := in.publish(ctx, userID, model.LiveSetCompleted, e.WorkoutID, e); err != nil {
+		return err
+	}
+
+	return in.publish(ctx, userID, model.LiveWorkoutUpdated, workout.ID, workout)
+}
+
+// applySet records the set into the entry. Weights without a unit are in
+// the unit of the entry.
+func applySet(entry *model.WorkoutExercise, e *Event, units model.Units) {
+	weightUnit := units.Weight
+	if entry.WeightUnit != "" {
+		weightUnit = entry.WeightUnit
+	}
+	if e.WeightUnit != "" {
+		weightUnit = model.WeightUnit(e.WeightUnit)
+	}
+
+	distanceUnit := units.Distance
+	if e.DistanceUnit != "" {
+		distanceUnit = model.DistanceUnit(e.DistanceUnit)
+	}
+
+	weights := model.NewWeight(e.Weight, weightUnit)
+
+	if weights > entry.ActualWeights || weights == entry.ActualWeights && e.Reps > entry.ActualReps {
+		entry.ActualWeights = weights
+		entry.ActualReps = e.Reps
+	}
+
+	entry.ActualDuration = max(entry.ActualDuration, e.Duration)
+	entry.ActualDistance = max(entry.ActualDistance, model.NewDistance(e.Distance, distanceUnit))
+
+	if e.Set >= entry.Sets {
+		entry.Done = true
+	}
+}
+
+// countRep pushes the rep to the clients following the session, reps are
+// only saved once their set is completed.
+func (in *Ingester) countRep(ctx context.Context, userID int, e *Event) error {
+	if _, err := in.models.Workouts.GetWorkoutByID(ctx, userID, e.WorkoutID); err != nil {
+		return err
+	}
+
+	return in.publish(ctx, userID, model.LiveRepCounted, e.WorkoutID, e)
+}
+
+func (in *Ingester) recordHeartRate(ctx context.Context, userID int, e *Event) error {
+	if _, err := in.models.Workouts.GetWorkoutByID(ctx, userID, e.WorkoutID); err != nil {
+		return err
+	}
+
+	hr := &model.HeartRate{WorkoutID: e.WorkoutID, BPM: e.BPM, RecordedAt: e.RecordedAt}
+
+	if err := in.models.HeartRates.Create(ctx, hr); err != nil {
+		return err
+	}
+
+	return in.publish(ctx, userID, model.LiveHeartRate, e.WorkoutID, hr)
+}
+
+func (in *Ingester) publish(ctx context.Context, userID int, eventType string, workoutID int, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return in.models.Live.Publish(ctx, userID, &model.LiveEvent{
+		Type:      eventType,
+		WorkoutID: workoutID,
+		Data:      payload,
+	})
+}
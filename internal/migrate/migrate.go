@@ -0,0 +1,370 @@
+// Package migrate applies the SQL migrations of the migrations directory.
+// The applied version is recorded in the same schema_migrations table the
+// migrate CLI uses, so both can be used on the same database. Migrations
+// are applied under a Postgres advisory lock so several instances starting
+// at once don't race to apply them.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrDirty     = errors.New("database is dirty, a migration failed halfway and must be fixed manually")
+	ErrNoChange  = errors.New("no change")
+	ErrUnknownDB = errors.New("database is at a version without a migration")
+	ErrBehind    = errors.New("database schema is behind")
+)
+
+const (
+	// time a single migration is allowed to run
+	migrationTimeout = 5 * time.Minute
+
+	// time to wait for another instance to finish migrating
+	lockTimeout = 10 * time.Minute
+
+	// key of the advisory lock held while migrating
+	lockKey = 7_204_173_385
+)
+
+// Migration is a numbered pair of up and down SQL files, e.g.
+// 000001_create_exercise_table.up.sql.
+type Migration struct {
+	Version uint
+	Name    string
+
+	up, down string
+}
+
+var fileRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migrator struct {
+	db         *sql.DB
+	fsys       fs.FS
+	migrations []*Migration
+	timeout    time.Duration // of reading the version
+}
+
+// New reads the migrations at the root of fsys. timeout bounds reading the
+// version of the database.
+func New(db *sql.DB, fsys fs.FS, timeout time.Duration) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		}
+
+		if match[3] == "up" {
+			m.up = entry.Name()
+		} else {
+			m.down = entry.Name()
+		}
+	}
+
+	migrations := []*Migration{}
+
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", m.Version)
+		}
+
+		migrations = append(migrations, m)
+	}
+
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+
+	return &Migrator{db: db, fsys: fsys, migrations: migrations, timeout: timeout}, nil
+}
+
+// Migrations returns the available migrations in order.
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Latest returns the version of the last available migration, 0 if there
+// are none.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version the database is at, 0 if no migration was
+// applied. It only reads, so it's safe to call from health checks.
+func (m *Migrator) Version(ctx context.Context) (uint, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return version(ctx, conn)
+}
+
+// Check returns ErrBehind if migrations newer than the database are
+// available, code expecting them can't run on it.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < m.Latest() {
+		return fmt.Errorf("%w: at version %d, %d is required", ErrBehind, current, m.Latest())
+	}
+
+	return nil
+}
+
+// MigrationStatus tells whether a migration is applied.
+type MigrationStatus struct {
+	*Migration
+	Applied bool
+}
+
+// Status returns every available migration and whether it's applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	current, err := m.Version(ctx)
+	if err != nil && !errors.Is(err, ErrDirty) {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		status[i] = MigrationStatus{Migration: migration, Applied: migration.Version <= current}
+	}
+
+	return status, err
+}
+
+// version reads the version of the database, the table recording it is
+// only created by the first migration so a database without it is at 0.
+func version(ctx context.Context, conn *sql.Conn) (uint, error) {
+	var exists bool
+
+	err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	var dirty bool
+
+	err = conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+
+	if dirty {
+		return uint(version), ErrDirty
+	}
+
+	return uint(version), nil
+}
+
+// withLock runs fn on a connection holding the migrations lock, waiting
+// for other instances to release it. The table recording the version is
+// created first if it doesn't exist.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquiring the migrations lock: %w", err)
+	}
+
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// Up applies every migration newer than the database. It returns
+// ErrNoChange if the database is up to date.
+func (m *Migrator) Up() ([]*Migration, error) {
+	applied := []*Migration{}
+
+	err := m.withLock(func(conn *sql.Conn) error {
+		current, err := m.lockedVersion(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			if err := m.apply(conn, migration.up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return applied, err
+	}
+
+	if len(applied) == 0 {
+		return nil, ErrNoChange
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the given number of migrations, all of them if steps is
+// 0. It returns ErrNoChange if no migration is applied.
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	rolledBack := []*Migration{}
+
+	err := m.withLock(func(conn *sql.Conn) error {
+		current, err := m.lockedVersion(conn)
+		if err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(m.migrations, func(migration *Migration) bool {
+			return migration.Version == current
+		})
+		if current != 0 && i == -1 {
+			return ErrUnknownDB
+		}
+
+		for ; i >= 0 && (steps == 0 || len(rolledBack) < steps); i-- {
+			migration := m.migrations[i]
+
+			previous := uint(0)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			if err := m.apply(conn, migration.down, previous); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return rolledBack, err
+	}
+
+	if len(rolledBack) == 0 {
+		return nil, ErrNoChange
+	}
+
+	return rolledBack, nil
+}
+
+// lockedVersion reads the version once the lock is held, another instance
+// may have migrated while waiting for it.
+func (m *Migrator) lockedVersion(conn *sql.Conn) (uint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	return version(ctx, conn)
+}
+
+// apply runs the file and records the version the database ends up at in
+// the same transaction, so a failed migration leaves nothing behind.
+func (m *Migrator) apply(conn *sql.Conn, file string, version uint) error {
+	script, err := fs.ReadFile(m.fsys, file)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+
+	if version > 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, dirty) VALUES($1, FALSE)`, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty BOOLEAN NOT NULL
+	)
+	`
+
+	_, err := conn.ExecContext(ctx, query)
+
+	return err
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records an action taken on a user's account.
+type AuditEntry struct {
+	ID        int            `json:"id"`
+	UserID    int            `json:"-"`
+	Action    string         `json:"action"`
+	Details   map[string]any `json:"details,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+const (
+	AuditDataExported   = "data exported"
+	AuditAccountDeleted = "account deleted"
+	AuditRoleChanged    = "role changed"
+)
+
+type AuditRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (r *AuditRepository) Record(ctx context.Context, entry *AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO audit_log(user_id, action, details)
+	VALUES($1, $2, $3)
+	RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, entry.UserID, entry.Action, details).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+	)
+}
+
+// GetAll returns the audit entries of the user, most recent first.
+func (r *AuditRepository) GetAll(ctx context.Context, userID int) ([]*AuditEntry, error) {
+	query := `
+	SELECT id, user_id, action, details, created_at
+	FROM audit_log
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Action,
+			&details,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+type BlockType string
+
+const (
+	BlockStraightSets BlockType = "straight sets"
+	BlockSuperset               = "superset"
+	BlockCircuit                = "circuit"
+	BlockEMOM                   = "emom"
+	BlockAMRAP                  = "amrap"
+)
+
+func GetBlockType(s string) (BlockType, error) {
+	switch s {
+	case "straight sets":
+		return BlockStraightSets, nil
+	case "superset":
+		return BlockSuperset, nil
+	case "circuit":
+		return BlockCircuit, nil
+	case "emom":
+		return BlockEMOM, nil
+	case "amrap":
+		return BlockAMRAP, nil
+	default:
+		return "", errors.New("invalid block type")
+	}
+}
+
+// WorkoutBlock groups consecutive exercises of a workout, e.g. the A1/A2
+// exercises of a superset or the stations of a circuit. Exercises reference
+// their block using its order.
+type WorkoutBlock struct {
+	ID    int       `json:"id"`
+	Order int       `json:"order"` // order of the block in the workout
+	Type  BlockType `json:"type"`
+
+	// Rounds is the number of times the whole block is performed
+	Rounds int `json:"rounds"`
+
+	// in seconds
+	RestBetweenRounds int `json:"rest_between_rounds,omitempty"`
+
+	// Duration is the time cap of EMOM and AMRAP blocks in seconds
+	Duration int `json:"duration,omitempty"`
+	Version  int `json:"-"`
+}
+
+func (b WorkoutBlock) Validate(v *validator.Validator) {
+	v.Check(b.Rounds > 0, "rounds", "must be a positive number")
+	v.Check(b.Rounds <= 100, "rounds", "must not be more than 100")
+
+	v.Check(b.RestBetweenRounds >= 0, "rest_between_rounds", "must be a positive number")
+	v.Check(b.RestBetweenRounds < 15*60, "rest_between_rounds", "must be less than 15 minutes")
+
+	if b.Type == BlockEMOM || b.Type == BlockAMRAP {
+		v.Check(b.Duration > 0, "duration", "must be a positive number")
+		v.Check(b.Duration <= 2*60*60, "duration", "must not be more than 2 hours")
+	} else {
+		v.Check(b.Duration == 0, "duration", "is only allowed for emom and amrap blocks")
+	}
+}
+
+// validateBlocks checks the blocks of a workout and the way exercises are
+// assigned to them.
+func validateBlocks(v *validator.Validator, blocks []WorkoutBlock, exercises []WorkoutExercise) {
+	types := make(map[int]BlockType, len(blocks))
+
+	for _, block := range blocks {
+		v.Check(block.Order >= 1 && block.Order <= len(blocks), "blocks", "are not ordered correctly")
+		_, found := types[block.Order]
+		v.Check(!found, "blocks", "must have unique orders")
+
+		types[block.Order] = block.Type
+		block.Validate(v)
+	}
+
+	// orders of the exercises in each block
+	members := make(map[int][]int, len(blocks))
+
+	for _, exercise := range exercises {
+		if exercise.Block == 0 {
+			continue
+		}
+
+		_, found := types[exercise.Block]
+		v.Check(found, "block", "must reference an existing block")
+		members[exercise.Block] = append(members[exercise.Block], exercise.Order)
+	}
+
+	for order, blockType := range types {
+		orders := members[order]
+		if len(orders) == 0 {
+			v.AddError("blocks", "must include at least one exercise")
+			continue
+		}
+
+		if blockType == BlockSuperset || blockType == BlockCircuit {
+			v.Check(len(orders) >= 2, "blocks", "supersets and circuits must include at least two exercises")
+		}
+
+		// exercises of a block must follow each other
+		slices.Sort(orders)
+		v.Check(orders[len(orders)-1]-orders[0]+1 == len(orders), "blocks", "exercises of a block must be consecutive")
+	}
+}
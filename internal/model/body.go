@@ -0,0 +1,501 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// Circumferences of body parts in centimeters.
+type Circumferences struct {
+	Neck  float64 `json:"neck,omitempty"`
+	Chest float64 `json:"chest,omitempty"`
+	Waist float64 `json:"waist,omitempty"`
+	Hips  float64 `json:"hips,omitempty"`
+	Arm   float64 `json:"arm,omitempty"`
+	Thigh float64 `json:"thigh,omitempty"`
+	Calf  float64 `json:"calf,omitempty"`
+}
+
+// ProgressPhoto is the metadata of a photo stored elsewhere.
+type ProgressPhoto struct {
+	URL  string `json:"url"`
+	Pose string `json:"pose,omitempty"` // front, side or back
+}
+
+// BodyMeasurement is a snapshot of the user's body at a point in time. Only
+// the values measured at that time are set.
+type BodyMeasurement struct {
+	ID             int             `json:"id"`
+	UserID         int             `json:"-"`
+	MeasuredAt     time.Time       `json:"measured_at"`
+	Weight         Weight          `json:"weight,omitempty"`
+	BodyFat        float64         `json:"body_fat,omitempty"` // percentage
+	Circumferences Circumferences  `json:"circumferences"`
+	Photos         []ProgressPhoto `json:"photos"`
+	Notes          string          `json:"notes,omitempty"`
+	Version        int             `json:"-"`
+
+	// units the measurement is presented in, see SetUnits.
+	units Units
+}
+
+func (m BodyMeasurement) Validate(v *validator.Validator) {
+	v.Check(!m.MeasuredAt.IsZero(), "measured_at", "must be provided")
+	v.Check(m.MeasuredAt.Before(time.Now().Add(24*time.Hour)), "measured_at", "must not be in the future")
+
+	v.Check(m.Weight != 0 || m.BodyFat != 0 || m.Circumferences != Circumferences{} || len(m.Photos) != 0,
+		"measurement", "must include at least one value")
+
+	v.Check(m.Weight >= 0, "weight", "must be a positive number")
+	v.Check(m.Weight < NewWeight(1000, Kilograms), "weight", "must be less than 1000 kg")
+
+	v.Check(m.BodyFat >= 0, "body_fat", "must be a positive number")
+	v.Check(m.BodyFat < 100, "body_fat", "must be less than 100")
+
+	circumferences := map[string]float64{
+		"circumferences.neck":  m.Circumferences.Neck,
+		"circumferences.chest": m.Circumferences.Chest,
+		"circumferences.waist": m.Circumferences.Waist,
+		"circumferences.hips":  m.Circumferences.Hips,
+		"circumferences.arm":   m.Circumferences.Arm,
+		"circumferences.thigh": m.Circumferences.Thigh,
+		"circumferences.calf":  m.Circumferences.Calf,
+	}
+
+	for key, value := range circumferences {
+		v.Check(value >= 0, key, "must be a positive number")
+		v.Check(value < 300, key, "must be less than 300 cm")
+	}
+
+	v.Check(len(m.Photos) <= 10, "photos", "must not be more than 10 photos")
+	for _, photo := range m.Photos {
+		v.Check(validator.URLRX.MatchString(photo.URL), "photos", "must have valid urls")
+		v.Check(validator.In(photo.Pose, []string{"", "front", "side", "back"}), "photos", "pose must be front, side or back")
+	}
+
+	v.Check(len(m.Notes) <= 1000, "notes", "must not be more than 1000 bytes")
+}
+
+// SetUnits sets the unit the measurement's weight is presented in.
+func (m *BodyMeasurement) SetUnits(units Units) {
+	m.units = units
+}
+
+// MarshalJSON presents the weight in the measurement's unit instead of its
+// canonical form.
+func (m BodyMeasurement) MarshalJSON() ([]byte, error) {
+	type alias BodyMeasurement
+
+	unit := m.units.Weight
+	if unit == "" {
+		unit = DefaultUnits.Weight
+	}
+
+	output := struct {
+		alias
+		Weight     float64    `json:"weight,omitempty"`
+		WeightUnit WeightUnit `json:"weight_unit,omitempty"`
+	}{
+		alias:  alias(m),
+		Weight: m.Weight.In(unit),
+	}
+
+	if m.Weight != 0 {
+		output.WeightUnit = unit
+	}
+
+	return json.Marshal(output)
+}
+
+// metricColumns maps the metrics that can be tracked over time to their
+// columns.
+var metricColumns = map[string]string{
+	"weight":   "weight",
+	"body_fat": "body_fat",
+	"neck":     "neck",
+	"chest":    "chest",
+	"waist":    "waist",
+	"hips":     "hips",
+	"arm":      "arm",
+	"thigh":    "thigh",
+	"calf":     "calf",
+}
+
+// ValidMetric reports whether metric can be used with GetSeries.
+func ValidMetric(metric string) bool {
+	_, ok := metricColumns[metric]
+	return ok
+}
+
+// TrendPoint is a value of a metric at a point in time along with the
+// average of the values measured during the window ending at that point.
+type TrendPoint struct {
+	MeasuredAt    time.Time `json:"measured_at"`
+	Value         float64   `json:"value"`
+	MovingAverage float64   `json:"moving_average"`
+}
+
+// TrendSummary describes how a metric changed over a series.
+type TrendSummary struct {
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Change float64 `json:"change"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// CalculateTrend fills the moving average of each point using the points
+// measured within window before it. points must be sorted by time.
+func CalculateTrend(points []TrendPoint, window time.Duration) TrendSummary {
+	if len(points) == 0 {
+		return TrendSummary{}
+	}
+
+	summary := TrendSummary{
+		Start: points[0].Value,
+		End:   points[len(points)-1].Value,
+		Min:   points[0].Value,
+		Max:   points[0].Value,
+	}
+
+	start, sum := 0, 0.0
+
+	for i := range points {
+		sum += points[i].Value
+
+		for points[i].MeasuredAt.Sub(points[start].MeasuredAt) >= window {
+			sum -= points[start].Value
+			start++
+		}
+
+		points[i].MovingAverage = round(sum/float64(i-start+1), 2)
+
+		summary.Min = math.Min(summary.Min, points[i].Value)
+		summary.Max = math.Max(summary.Max, points[i].Value)
+	}
+
+	summary.Change = round(summary.End-summary.Start, 2)
+
+	return summary
+}
+
+func round(value float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(value*p) / p
+}
+
+type BodyMeasurementRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (r *BodyMeasurementRepository) Create(ctx context.Context, m *BodyMeasurement) error {
+	photos, err := json.Marshal(m.Photos)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO body_measurements(user_id, measured_at, weight, body_fat, neck,
+	chest, waist, hips, arm, thigh, calf, photos, notes)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING id, version
+	`
+	args := []any{
+		m.UserID,
+		m.MeasuredAt,
+		m.Weight,
+		m.BodyFat,
+		m.Circumferences.Neck,
+		m.Circumferences.Chest,
+		m.Circumferences.Waist,
+		m.Circumferences.Hips,
+		m.Circumferences.Arm,
+		m.Circumferences.Thigh,
+		m.Circumferences.Calf,
+		photos,
+		m.Notes,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, args...).Scan(&m.ID, &m.Version)
+}
+
+func (r *BodyMeasurementRepository) Get(ctx context.Context, userID, id int) (*BodyMeasurement, error) {
+	query := `
+	SELECT id, user_id, measured_at, weight, body_fat, neck, chest, waist,
+	hips, arm, thigh, calf, photos, notes, version
+	FROM body_measurements
+	WHERE user_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var m BodyMeasurement
+	var photos []byte
+
+	err := r.db.QueryRowContext(ctx, query, userID, id).Scan(
+		&m.ID,
+		&m.UserID,
+		&m.MeasuredAt,
+		&m.Weight,
+		&m.BodyFat,
+		&m.Circumferences.Neck,
+		&m.Circumferences.Chest,
+		&m.Circumferences.Waist,
+		&m.Circumferences.Hips,
+		&m.Circumferences.Arm,
+		&m.Circumferences.Thigh,
+		&m.Circumferences.Calf,
+		&photos,
+		&m.Notes,
+		&m.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(photos, &m.Photos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal photos: %w", err)
+	}
+
+	return &m, nil
+}
+
+// GetAll returns a page of the user's measurements taken from from up to,
+// but not including, to. Zero times leave the range open.
+func (r *BodyMeasurementRepository) GetAll(ctx context.Context, userID int, from, to time.Time, filters Filters) ([]*BodyMeasurement, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) OVER(), id, user_id, measured_at, weight, body_fat, neck,
+	chest, waist, hips, arm, thigh, calf, photos, notes, version
+	FROM body_measurements
+	WHERE user_id = $1
+	AND ($2::timestamptz IS NULL OR measured_at >= $2)
+	AND ($3::timestamptz IS NULL OR measured_at < $3)
+	ORDER BY %s %s, id ASC
+	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	args := []any{
+		userID,
+		sql.NullTime{Time: from, Valid: !from.IsZero()},
+		sql.NullTime{Time: to, Valid: !to.IsZero()},
+		filters.limit(),
+		filters.offset(),
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	measurements := []*BodyMeasurement{}
+
+	for rows.Next() {
+		var m BodyMeasurement
+		var photos []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&m.ID,
+			&m.UserID,
+			&m.MeasuredAt,
+			&m.Weight,
+			&m.BodyFat,
+			&m.Circumferences.Neck,
+			&m.Circumferences.Chest,
+			&m.Circumferences.Waist,
+			&m.Circumferences.Hips,
+			&m.Circumferences.Arm,
+			&m.Circumferences.Thigh,
+			&m.Circumferences.Calf,
+			&photos,
+			&m.Notes,
+			&m.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if err := json.Unmarshal(photos, &m.Photos); err != nil {
+			return nil, Metadata{}, fmt.Errorf("failed to unmarshal photos: %w", err)
+		}
+
+		measurements = append(measurements, &m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return measurements, metadata, nil
+}
+
+// GetLatestWeight returns the most recent bodyweight of the user.
+func (r *BodyMeasurementRepository) GetLatestWeight(ctx context.Context, userID int) (Weight, error) {
+	query := `
+	SELECT weight
+	FROM body_measurements
+	WHERE user_id = $1 AND weight > 0
+	ORDER BY measured_at DESC
+	LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var weight Weight
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&weight)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return weight, nil
+}
+
+// GetSeries returns the values of the metric measured from from up to, but
+// not including, to sorted by time. Weights are returned in grams. Zero
+// times leave the range open.
+func (r *BodyMeasurementRepository) GetSeries(ctx context.Context, userID int, metric string, from, to time.Time) ([]TrendPoint, error) {
+	column, ok := metricColumns[metric]
+	if !ok {
+		panic("unsafe metric parameter: " + metric)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT measured_at, %[1]s
+	FROM body_measurements
+	WHERE user_id = $1 AND %[1]s > 0
+	AND ($2::timestamptz IS NULL OR measured_at >= $2)
+	AND ($3::timestamptz IS NULL OR measured_at < $3)
+	ORDER BY measured_at`, column)
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	args := []any{
+		userID,
+		sql.NullTime{Time: from, Valid: !from.IsZero()},
+		sql.NullTime{Time: to, Valid: !to.IsZero()},
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []TrendPoint{}
+
+	for rows.Next() {
+		var point TrendPoint
+
+		if err := rows.Scan(&point.MeasuredAt, &point.Value); err != nil {
+			return nil, err
+		}
+
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+func (r *BodyMeasurementRepository) Update(ctx context.Context, m *BodyMeasurement) error {
+	photos, err := json.Marshal(m.Photos)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE body_measurements
+	SET measured_at = $1, weight = $2, body_fat = $3, neck = $4, chest = $5,
+	waist = $6, hips = $7, arm = $8, thigh = $9, calf = $10, photos = $11,
+	notes = $12, version = version + 1
+	WHERE id = $13 AND user_id = $14 AND version = $15
+	RETURNING version
+	`
+	args := []any{
+		m.MeasuredAt,
+		m.Weight,
+		m.BodyFat,
+		m.Circumferences.Neck,
+		m.Circumferences.Chest,
+		m.Circumferences.Waist,
+		m.Circumferences.Hips,
+		m.Circumferences.Arm,
+		m.Circumferences.Thigh,
+		m.Circumferences.Calf,
+		photos,
+		m.Notes,
+		m.ID,
+		m.UserID,
+		m.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(&m.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *BodyMeasurementRepository) Delete(ctx context.Context, userID, id int) error {
+	query := `DELETE FROM body_measurements WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/rrule"
+)
+
+// Date is a calendar date without a time of day nor a time zone. It's held
+// as midnight in UTC.
+type Date struct {
+	time.Time
+}
+
+// NewDate returns the date of t in its location.
+func NewDate(t time.Time) Date {
+	return Date{rrule.Date(t)}
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("%q is not a date in the form of YYYY-MM-DD", s)
+	}
+
+	return Date{t}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(time.DateOnly)
+}
+
+// AddDays returns the date n days after d.
+func (d Date) AddDays(n int) Date {
+	return Date{d.AddDate(0, 0, n)}
+}
+
+// At returns the instant of the clock time, formatted as "15:04", on the
+// date in loc.
+func (d Date) At(clock string, loc *time.Location) time.Time {
+	t, _ := time.Parse("15:04", clock)
+
+	return time.Date(d.Year(), d.Month(), d.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	date, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+
+	*d = date
+
+	return nil
+}
+
+// Scan reads DATE columns.
+func (d *Date) Scan(src any) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("can't scan %T into a date", src)
+	}
+
+	*d = NewDate(t)
+
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -24,6 +24,10 @@ type Exercise struct {
 
 	ImageURL string `json:"image_url"`
 
+	// Measurement decides which values are tracked when performing the
+	// exercise in a workout
+	Measurement Measurement `json:"measurement"`
+
 	// Version is used for Version control in databae
 	Version int `json:"-"`
 }
@@ -39,16 +43,20 @@ func (e Exercise) Validate(v *validator.Validator) {
 	v.Check(len(e.AdditionalInfo) < 10000, "additional_info", "must be less than 10000 bytes")
 
 	v.Check(validator.URLRX.MatchString(e.ImageURL), "image_url", "must be a valid url")
+
+	_, err := GetMeasurement(string(e.Measurement))
+	v.Check(err == nil, "measurement", "invalid measurement")
 }
 
 type ExerciseRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func (r *ExerciseRepository) Create(exercise *Exercise) error {
+func (r *ExerciseRepository) Create(ctx context.Context, exercise *Exercise) error {
 	query := `
-	INSERT INTO exercises(name, muscle, instructions, additional_info, image_url)
-	VALUES($1, $2, $3, $4, $5)
+	INSERT INTO exercises(name, muscle, instructions, additional_info, image_url, measurement)
+	VALUES($1, $2, $3, $4, $5, $6)
 	RETURNING id, version
 	`
 	args := []any{
@@ -57,9 +65,10 @@ func (r *ExerciseRepository) Create(exercise *Exercise) error {
 		exercise.Instructions,
 		exercise.AdditionalInfo,
 		exercise.ImageURL,
+		exercise.Measurement,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(&exercise.ID, &exercise.Version)
@@ -75,14 +84,14 @@ func (r *ExerciseRepository) Create(exercise *Exercise) error {
 	return nil
 }
 
-func (r *ExerciseRepository) Get(id int) (*Exercise, error) {
+func (r *ExerciseRepository) Get(ctx context.Context, id int) (*Exercise, error) {
 	query := `
-	SELECT id, name, muscle, instructions, additional_info, image_url, version
+	SELECT id, name, muscle, instructions, additional_info, image_url, measurement, version
 	FROM exercises
 	WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	exercise := &Exercise{ID: id}
@@ -94,6 +103,7 @@ func (r *ExerciseRepository) Get(id int) (*Exercise, error) {
 		&exercise.Instructions,
 		&exercise.AdditionalInfo,
 		&exercise.ImageURL,
+		&exercise.Measurement,
 		&exercise.Version,
 	)
 	if err != nil {
@@ -108,20 +118,55 @@ func (r *ExerciseRepository) Get(id int) (*Exercise, error) {
 	return exercise, nil
 }
 
-func (r *ExerciseRepository) Search(name, muscle string, filters Filters) ([]*Exercise, Metadata, error) {
+// GetByName returns the exercise with the exact given name.
+func (r *ExerciseRepository) GetByName(ctx context.Context, name string) (*Exercise, error) {
+	query := `
+	SELECT id, name, muscle, instructions, additional_info, image_url, measurement, version
+	FROM exercises
+	WHERE name = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var exercise Exercise
+
+	err := r.db.QueryRowContext(ctx, query, name).Scan(
+		&exercise.ID,
+		&exercise.Name,
+		&exercise.Muscle,
+		&exercise.Instructions,
+		&exercise.AdditionalInfo,
+		&exercise.ImageURL,
+		&exercise.Measurement,
+		&exercise.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &exercise, nil
+}
+
+func (r *ExerciseRepository) Search(ctx context.Context, name, muscle string, filters Filters) ([]*Exercise, Metadata, error) {
 	// We Use COUNT(*) OVER() to get the total number for metadata. we
 	// utilize postgres text search using to_tsvector for better string
 	// search. limi and offset are calculated based on the page and page
 	// size queries from the coming request.
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), id, name, muscle, instructions, additional_info, image_url, version
+	SELECT COUNT(*) OVER(), id, name, muscle, instructions, additional_info, image_url, measurement, version
 	FROM exercises
 	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
 	AND (to_tsvector('simple', muscle) @@ plainto_tsquery('simple', $2) OR $2 = '')
 	ORDER BY %s %s, id ASC
 	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	args := []any{name, muscle, filters.limit(), filters.offset()}
@@ -147,6 +192,7 @@ func (r *ExerciseRepository) Search(name, muscle string, filters Filters) ([]*Ex
 			&exercise.Instructions,
 			&exercise.AdditionalInfo,
 			&exercise.ImageURL,
+			&exercise.Measurement,
 			&exercise.Version,
 		)
 
@@ -166,11 +212,12 @@ func (r *ExerciseRepository) Search(name, muscle string, filters Filters) ([]*Ex
 	return exercises, metadata, nil
 }
 
-func (r *ExerciseRepository) Update(exercise *Exercise) error {
+func (r *ExerciseRepository) Update(ctx context.Context, exercise *Exercise) error {
 	query := `
 	UPDATE exercises
-	SET name = $1, muscle = $2, instructions = $3, additional_info = $4, image_url = $5, version = version + 1
-	WHERE id = $6 AND version = $7
+	SET name = $1, muscle = $2, instructions = $3, additional_info = $4, image_url = $5,
+	measurement = $6, version = version + 1
+	WHERE id = $7 AND version = $8
 	RETURNING version
 	`
 
@@ -180,11 +227,12 @@ func (r *ExerciseRepository) Update(exercise *Exercise) error {
 		exercise.Instructions,
 		exercise.AdditionalInfo,
 		exercise.ImageURL,
+		exercise.Measurement,
 		exercise.ID,
 		exercise.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(&exercise.Version)
@@ -202,10 +250,10 @@ func (r *ExerciseRepository) Update(exercise *Exercise) error {
 	return nil
 }
 
-func (r *ExerciseRepository) Delete(id int) error {
+func (r *ExerciseRepository) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM exercises WHERE id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	result, err := r.db.ExecContext(ctx, query, id)
@@ -228,19 +276,35 @@ func (r *ExerciseRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *ExerciseRepository) GetByIDs(ids ...int) ([]*Exercise, error) {
+// InUse reports whether any workout has the exercise.
+func (r *ExerciseRepository) InUse(ctx context.Context, id int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM workouts_exercises WHERE exercise_id = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var used bool
+
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&used); err != nil {
+		return false, err
+	}
+
+	return used, nil
+}
+
+func (r *ExerciseRepository) GetByIDs(ctx context.Context, ids ...int) ([]*Exercise, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 
 	query := `
-	SELECT id, name, muscle, instructions, additional_info, image_url, version
+	SELECT id, name, muscle, instructions, additional_info, image_url, measurement, version
 	FROM exercises
 	WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	var exercises []*Exercise
@@ -255,6 +319,7 @@ func (r *ExerciseRepository) GetByIDs(ids ...int) ([]*Exercise, error) {
 			&exercise.Instructions,
 			&exercise.AdditionalInfo,
 			&exercise.ImageURL,
+			&exercise.Measurement,
 			&exercise.Version,
 		)
 		if err != nil {
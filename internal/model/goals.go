@@ -0,0 +1,51 @@
+package model
+
+import "errors"
+
+type Goal string
+
+const (
+	GoalStrength       Goal = "strength"
+	GoalHypertrophy         = "hypertrophy"
+	GoalEndurance           = "endurance"
+	GoalWeightLoss          = "weight loss"
+	GoalGeneralFitness      = "general fitness"
+)
+
+func GetGoal(s string) (Goal, error) {
+	switch s {
+	case "strength":
+		return GoalStrength, nil
+	case "hypertrophy":
+		return GoalHypertrophy, nil
+	case "endurance":
+		return GoalEndurance, nil
+	case "weight loss":
+		return GoalWeightLoss, nil
+	case "general fitness":
+		return GoalGeneralFitness, nil
+	default:
+		return "", errors.New("invalid goal")
+	}
+}
+
+type Level string
+
+const (
+	LevelBeginner     Level = "beginner"
+	LevelIntermediate       = "intermediate"
+	LevelAdvanced           = "advanced"
+)
+
+func GetLevel(s string) (Level, error) {
+	switch s {
+	case "beginner":
+		return LevelBeginner, nil
+	case "intermediate":
+		return LevelIntermediate, nil
+	case "advanced":
+		return LevelAdvanced, nil
+	default:
+		return "", errors.New("invalid level")
+	}
+}
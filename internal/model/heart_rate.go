@@ -0,0 +1,82 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// HeartRate is a heart rate sample recorded by a wearable during a workout.
+type HeartRate struct {
+	ID         int64     `json:"-"`
+	WorkoutID  int       `json:"workout_id"`
+	BPM        int       `json:"bpm"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func (hr HeartRate) Validate(v *validator.Validator) {
+	v.Check(hr.BPM >= 20, "bpm", "must be at least 20")
+	v.Check(hr.BPM <= 250, "bpm", "must not be more than 250")
+	v.Check(!hr.RecordedAt.IsZero(), "recorded_at", "must be provided")
+	v.Check(hr.RecordedAt.Before(time.Now().Add(time.Minute)), "recorded_at", "must not be in the future")
+}
+
+type HeartRateRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (r *HeartRateRepository) Create(ctx context.Context, hr *HeartRate) error {
+	query := `
+	INSERT INTO heart_rates(workout_id, bpm, recorded_at)
+	VALUES($1, $2, $3)
+	RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, hr.WorkoutID, hr.BPM, hr.RecordedAt).Scan(&hr.ID)
+}
+
+// GetAll returns the heart rate samples of the owner's workout in the order
+// they were recorded, the samples of all of the owner's workouts if
+// workoutID is 0.
+func (r *HeartRateRepository) GetAll(ctx context.Context, ownerID, workoutID int) ([]*HeartRate, error) {
+	query := `
+	SELECT hr.id, hr.workout_id, hr.bpm, hr.recorded_at
+	FROM heart_rates AS hr
+	JOIN workouts AS w ON w.id = hr.workout_id
+	WHERE w.owner_id = $1 AND ($2 = 0 OR hr.workout_id = $2)
+	ORDER BY hr.workout_id, hr.recorded_at, hr.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, ownerID, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []*HeartRate{}
+
+	for rows.Next() {
+		var hr HeartRate
+
+		if err := rows.Scan(&hr.ID, &hr.WorkoutID, &hr.BPM, &hr.RecordedAt); err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, &hr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
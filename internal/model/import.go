@@ -0,0 +1,193 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning                = "running"
+	ImportCompleted              = "completed"
+	ImportFailed                 = "failed"
+)
+
+// ImportJob tracks the import of a user's workout history exported from
+// another app. Each imported session is stored as a completed workout.
+type ImportJob struct {
+	ID     int          `json:"id"`
+	UserID int          `json:"-"`
+	Source string       `json:"source"`
+	Status ImportStatus `json:"status"`
+
+	// number of sessions found in the file, imported and skipped
+	Total    int `json:"total"`
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+
+	// Errors explains why sessions or entries were skipped
+	Errors []string `json:"errors"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// maxImportErrors is the number of errors kept for a job.
+const maxImportErrors = 100
+
+// AddError records why part of the import was skipped.
+func (j *ImportJob) AddError(message string) {
+	if len(j.Errors) >= maxImportErrors {
+		return
+	}
+
+	j.Errors = append(j.Errors, message)
+}
+
+type ImportRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (r *ImportRepository) Create(ctx context.Context, job *ImportJob) error {
+	if job.Errors == nil {
+		job.Errors = []string{}
+	}
+
+	query := `
+	INSERT INTO imports(user_id, source, status, total)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at
+	`
+	args := []any{job.UserID, job.Source, job.Status, job.Total}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.CreatedAt)
+}
+
+func (r *ImportRepository) Get(ctx context.Context, userID, id int) (*ImportJob, error) {
+	query := `
+	SELECT id, user_id, source, status, total, imported, skipped, errors,
+	created_at, finished_at
+	FROM imports
+	WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	job, err := scanImportJob(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return job, nil
+}
+
+// GetAll returns the import jobs of the user, most recent first.
+func (r *ImportRepository) GetAll(ctx context.Context, userID int) ([]*ImportJob, error) {
+	query := `
+	SELECT id, user_id, source, status, total, imported, skipped, errors,
+	created_at, finished_at
+	FROM imports
+	WHERE user_id = $1
+	ORDER BY id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*ImportJob{}
+
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Update saves the progress of the job.
+func (r *ImportRepository) Update(ctx context.Context, job *ImportJob) error {
+	errs, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE imports
+	SET status = $1, imported = $2, skipped = $3, errors = $4, finished_at = $5
+	WHERE id = $6
+	`
+	args := []any{job.Status, job.Imported, job.Skipped, errs, job.FinishedAt, job.ID}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanImportJob reads a row of the imports table.
+func scanImportJob(row interface{ Scan(...any) error }) (*ImportJob, error) {
+	var job ImportJob
+	var errs []byte
+
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Source,
+		&job.Status,
+		&job.Total,
+		&job.Imported,
+		&job.Skipped,
+		&errs,
+		&job.CreatedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(errs, &job.Errors); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
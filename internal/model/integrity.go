@@ -0,0 +1,146 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// IntegrityIssue is inconsistent data found by a check, the validation of
+// the API can't catch it, e.g. rows written before a constraint existed or
+// left behind by a crashed worker.
+type IntegrityIssue struct {
+	Check       string
+	Description string
+	Count       int
+
+	// IDs of up to 10 of the offending rows
+	IDs []int64
+}
+
+var integrityChecks = []struct {
+	name        string
+	description string
+	query       string
+}{
+	{
+		name:        "empty workouts",
+		description: "workouts without any exercise",
+		query: `
+		SELECT w.id FROM workouts AS w
+		WHERE NOT EXISTS (SELECT 1 FROM workouts_exercises AS we WHERE we.workout_id = w.id)
+		`,
+	},
+	{
+		name:        "orphan block entries",
+		description: "workout entries grouped in a block the workout doesn't have",
+		query: `
+		SELECT we.id FROM workouts_exercises AS we
+		WHERE we.block_order <> 0 AND NOT EXISTS (
+			SELECT 1 FROM workout_blocks AS b
+			WHERE b.workout_id = we.workout_id AND b.block_order = we.block_order
+		)
+		`,
+	},
+	{
+		name:        "foreign schedules",
+		description: "schedules of workouts owned by another user",
+		query: `
+		SELECT s.id FROM schedules AS s
+		JOIN workouts AS w ON w.id = s.workout_id
+		WHERE w.owner_id IS DISTINCT FROM s.user_id
+		`,
+	},
+	{
+		name:        "duplicate exercises",
+		description: "exercises whose names only differ in case",
+		query: `
+		SELECT MIN(id) FROM exercises
+		GROUP BY LOWER(name)
+		HAVING COUNT(*) > 1
+		`,
+	},
+	{
+		name:        "stuck imports",
+		description: "imports pending or running for over an hour",
+		query: `
+		SELECT id FROM imports
+		WHERE status IN ('pending', 'running') AND created_at < NOW() - INTERVAL '1 hour'
+		`,
+	},
+	{
+		name:        "stuck notifications",
+		description: "notifications whose sending lease expired over an hour ago",
+		query: `
+		SELECT id FROM notification_jobs
+		WHERE status = 'sending' AND locked_until < NOW() - INTERVAL '1 hour'
+		`,
+	},
+	{
+		name:        "stuck webhook deliveries",
+		description: "webhook deliveries whose sending lease expired over an hour ago",
+		query: `
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'sending' AND locked_until < NOW() - INTERVAL '1 hour'
+		`,
+	},
+}
+
+type IntegrityRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// Check runs every integrity check and returns the ones that found issues.
+func (r *IntegrityRepository) Check(ctx context.Context) ([]*IntegrityIssue, error) {
+	issues := []*IntegrityIssue{}
+
+	for _, check := range integrityChecks {
+		issue, err := r.run(ctx, check.query)
+		if err != nil {
+			return nil, err
+		}
+
+		if issue.Count == 0 {
+			continue
+		}
+
+		issue.Check = check.name
+		issue.Description = check.description
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func (r *IntegrityRepository) run(ctx context.Context, query string) (*IntegrityIssue, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issue := &IntegrityIssue{IDs: []int64{}}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		if issue.Count < 10 {
+			issue.IDs = append(issue.IDs, id)
+		}
+
+		issue.Count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
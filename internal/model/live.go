@@ -0,0 +1,125 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+	"github.com/redis/go-redis/v9"
+)
+
+// Types of live session events.
+const (
+	LiveSetCompleted   = "set.completed"
+	LiveRestStarted    = "rest.started"
+	LiveWorkoutUpdated = "workout.updated"
+	LiveRepCounted     = "rep.counted"
+	LiveHeartRate      = "heart_rate"
+)
+
+// types of events clients can publish, the others are published by the
+// server when the workout is saved or a device reports to it.
+var liveClientEvents = []string{LiveSetCompleted, LiveRestStarted}
+
+// LiveEvent is pushed to every client following an active workout session
+// of the user, e.g. the phone and the watch app.
+type LiveEvent struct {
+	Type      string          `json:"type"`
+	WorkoutID int             `json:"workout_id"`
+	Data      json.RawMessage `json:"data,omitempty"`
+
+	// ClientID identifies the client that published the event so it can
+	// ignore its own events, it's optional.
+	ClientID string    `json:"client_id,omitempty"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// Validate checks events published by clients.
+func (e LiveEvent) Validate(v *validator.Validator) {
+	v.Check(slices.Contains(liveClientEvents, e.Type), "type", "must be set.completed or rest.started")
+	v.Check(len(e.Data) <= 4096, "data", "must not be more than 4096 bytes")
+	v.Check(len(e.ClientID) <= 100, "client_id", "must not be more than 100 bytes")
+}
+
+// LiveRepository relays live session events through Redis pub/sub so
+// clients connected to different instances get the same events.
+type LiveRepository struct {
+	redis   *redis.Client
+	timeout time.Duration
+}
+
+// liveChannel is the pub/sub channel of the user's session of a workout.
+func liveChannel(userID, workoutID int) string {
+	return fmt.Sprintf("live:%d:%d", userID, workoutID)
+}
+
+// Publish sends the event to every subscriber of the user's session of the
+// event's workout.
+func (r *LiveRepository) Publish(ctx context.Context, userID int, e *LiveEvent) error {
+	if e.SentAt.IsZero() {
+		e.SentAt = time.Now()
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := r.redis.Publish(ctx, liveChannel(userID, e.WorkoutID), payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish on redis: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe returns the events of the user's session of the workout
+// published from now on. The channel is closed once ctx is done.
+func (r *LiveRepository) Subscribe(ctx context.Context, userID, workoutID int) (<-chan *LiveEvent, error) {
+	sub := r.redis.Subscribe(ctx, liveChannel(userID, workoutID))
+
+	// wait for the subscription to be confirmed so no event published
+	// after returning is missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("failed to subscribe on redis: %w", err)
+	}
+
+	events := make(chan *LiveEvent)
+
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		messages := sub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var e LiveEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					continue
+				}
+
+				select {
+				case events <- &e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import "errors"
+
+// Measurement is the way an exercise is tracked, e.g. planks are tracked by
+// duration while farmer's carries are tracked by distance and weight.
+type Measurement string
+
+const (
+	MeasurementReps              Measurement = "reps"
+	MeasurementDuration                      = "duration"
+	MeasurementDistance                      = "distance"
+	MeasurementRepsAndWeight                 = "reps and weight"
+	MeasurementDistanceAndWeight             = "distance and weight"
+)
+
+func GetMeasurement(s string) (Measurement, error) {
+	switch s {
+	case "reps":
+		return MeasurementReps, nil
+	case "duration":
+		return MeasurementDuration, nil
+	case "distance":
+		return MeasurementDistance, nil
+	case "reps and weight":
+		return MeasurementRepsAndWeight, nil
+	case "distance and weight":
+		return MeasurementDistanceAndWeight, nil
+	default:
+		return "", errors.New("invalid measurement")
+	}
+}
+
+func (m Measurement) hasReps() bool {
+	return m == MeasurementReps || m == MeasurementRepsAndWeight
+}
+
+func (m Measurement) hasWeights() bool {
+	return m == MeasurementRepsAndWeight || m == MeasurementDistanceAndWeight
+}
+
+func (m Measurement) hasDuration() bool {
+	return m == MeasurementDuration
+}
+
+func (m Measurement) hasDistance() bool {
+	return m == MeasurementDistance || m == MeasurementDistanceAndWeight
+}
@@ -1,10 +1,16 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
+	"github.com/XSAM/otelsql"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 var (
@@ -14,32 +20,84 @@ var (
 )
 
 type Model struct {
-	Exercises *ExerciseRepository
-	Users     *UserRepository
-	Tokens    *TokenRepository
-	Workouts  *WorkoutRepository
+	// DB is the connection pool the repositories share.
+	DB *sql.DB
+
+	Exercises     *ExerciseRepository
+	Users         *UserRepository
+	Tokens        *TokenRepository
+	Workouts      *WorkoutRepository
+	Shares        *ShareRepository
+	Templates     *TemplateRepository
+	Measurements  *BodyMeasurementRepository
+	Audit         *AuditRepository
+	Imports       *ImportRepository
+	Schedules     *ScheduleRepository
+	Notifications *NotificationRepository
+	Webhooks      *WebhookRepository
+	Live          *LiveRepository
+	HeartRates    *HeartRateRepository
+	Integrity     *IntegrityRepository
+	RateLimits    *RateLimitRepository
+
+	redis *redis.Client
 }
 
-func New(dsn string) (*Model, error) {
-	db, err := sql.Open("postgres", dsn)
+func New(cfg config.Config) (*Model, error) {
+	db, err := otelsql.Open("postgres", cfg.DSN, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.QueryTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
 	}
 
-	redis, err := newRedisClient()
+	redis, err := newRedisClient(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("connecting to redis at %s: %w", cfg.RedisAddr, err)
 	}
 
+	timeout := cfg.QueryTimeout
+
+	workouts := &WorkoutRepository{db: db, timeout: timeout}
+
 	return &Model{
-		Exercises: &ExerciseRepository{db: db},
-		Users:     &UserRepository{db: db},
-		Tokens:    &TokenRepository{redis: redis},
-		Workouts:  &WorkoutRepository{db: db},
+		DB:            db,
+		Exercises:     &ExerciseRepository{db: db, timeout: timeout},
+		Users:         &UserRepository{db: db, timeout: timeout},
+		Tokens:        &TokenRepository{redis: redis, timeout: timeout, ttl: cfg.SessionTTL},
+		Workouts:      workouts,
+		Shares:        &ShareRepository{db: db, timeout: timeout},
+		Templates:     &TemplateRepository{db: db, workouts: workouts, timeout: timeout},
+		Measurements:  &BodyMeasurementRepository{db: db, timeout: timeout},
+		Audit:         &AuditRepository{db: db, timeout: timeout},
+		Imports:       &ImportRepository{db: db, timeout: timeout},
+		Schedules:     &ScheduleRepository{db: db, timeout: timeout},
+		Notifications: &NotificationRepository{db: db, timeout: timeout},
+		Webhooks:      &WebhookRepository{db: db, timeout: timeout},
+		Live:          &LiveRepository{redis: redis, timeout: timeout},
+		HeartRates:    &HeartRateRepository{db: db, timeout: timeout},
+		Integrity:     &IntegrityRepository{db: db, timeout: timeout},
+		RateLimits:    &RateLimitRepository{redis: redis, timeout: timeout},
+		redis:         redis,
 	}, nil
 
 }
+
+// PingDB checks the connection to Postgres.
+func (m *Model) PingDB(ctx context.Context) error {
+	return m.DB.PingContext(ctx)
+}
+
+// PingRedis checks the connection to Redis.
+func (m *Model) PingRedis(ctx context.Context) error {
+	return m.redis.Ping(ctx).Err()
+}
@@ -0,0 +1,445 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+type NotificationChannel string
+
+const (
+	ChannelEmail   NotificationChannel = "email"
+	ChannelWebhook                     = "webhook"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSending                    = "sending"
+	NotificationSent                       = "sent"
+	NotificationFailed                     = "failed"
+
+	// the user opted out of the channel before the job was sent
+	NotificationCancelled = "cancelled"
+)
+
+// NotificationPreferences are the channels the user opted in to receive
+// reminders of planned workouts on.
+type NotificationPreferences struct {
+	UserID           int    `json:"-"`
+	EmailReminders   bool   `json:"email_reminders"`
+	WebhookReminders bool   `json:"webhook_reminders"`
+	WebhookURL       string `json:"webhook_url,omitempty"`
+
+	// minutes before a planned workout the reminder is sent
+	RemindBefore int `json:"remind_before"`
+
+	Version int `json:"-"`
+}
+
+var DefaultNotificationPreferences = NotificationPreferences{RemindBefore: 60}
+
+func (p NotificationPreferences) Validate(v *validator.Validator) {
+	v.Check(p.RemindBefore >= 0, "remind_before", "must be a positive number")
+	v.Check(p.RemindBefore <= 24*60, "remind_before", "must not be more than a day")
+
+	if p.WebhookReminders || p.WebhookURL != "" {
+		v.Check(validator.HTTPURLRX.MatchString(p.WebhookURL), "webhook_url", "must be a valid http or https url")
+		v.Check(len(p.WebhookURL) <= 500, "webhook_url", "must not be more than 500 bytes")
+	}
+}
+
+// Subscriber is a user who opted in to reminders.
+type Subscriber struct {
+	UserID      int
+	Email       string
+	TimeZone    string
+	Units       Units
+	Preferences NotificationPreferences
+}
+
+// NotificationJob is a notification queued to be sent over a channel. Jobs
+// that fail are retried with backoff until they run out of attempts.
+type NotificationJob struct {
+	ID      int64               `json:"id"`
+	UserID  int                 `json:"-"`
+	Channel NotificationChannel `json:"channel"`
+
+	// Address is the email address or webhook url the job is sent to.
+	Address string         `json:"-"`
+	Subject string         `json:"subject"`
+	Body    string         `json:"body"`
+	Data    map[string]any `json:"data,omitempty"`
+
+	// DedupeKey prevents queueing the same notification twice, it's
+	// optional.
+	DedupeKey string `json:"-"`
+
+	Status      NotificationStatus `json:"status"`
+	RunAt       time.Time          `json:"run_at"`
+	Attempts    int                `json:"attempts"`
+	MaxAttempts int                `json:"-"`
+	LastError   string             `json:"last_error,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	SentAt      *time.Time         `json:"sent_at,omitempty"`
+}
+
+type NotificationRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// GetPreferences returns the user's preferences, the default ones if they
+// have never been set.
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID int) (*NotificationPreferences, error) {
+	query := `
+	SELECT user_id, email_reminders, webhook_reminders, webhook_url,
+	remind_before, version
+	FROM notification_preferences
+	WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var p NotificationPreferences
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&p.UserID,
+		&p.EmailReminders,
+		&p.WebhookReminders,
+		&p.WebhookURL,
+		&p.RemindBefore,
+		&p.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			p = DefaultNotificationPreferences
+			p.UserID = userID
+			return &p, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// UpdatePreferences saves the preferences, creating them on the first
+// update. Version is 0 for preferences that were never saved.
+func (r *NotificationRepository) UpdatePreferences(ctx context.Context, p *NotificationPreferences) error {
+	query := `
+	INSERT INTO notification_preferences AS np(user_id, email_reminders,
+	webhook_reminders, webhook_url, remind_before)
+	VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id) DO UPDATE
+	SET email_reminders = EXCLUDED.email_reminders,
+	webhook_reminders = EXCLUDED.webhook_reminders,
+	webhook_url = EXCLUDED.webhook_url,
+	remind_before = EXCLUDED.remind_before,
+	version = np.version + 1
+	WHERE np.version = $6
+	RETURNING version
+	`
+	args := []any{
+		p.UserID,
+		p.EmailReminders,
+		p.WebhookReminders,
+		p.WebhookURL,
+		p.RemindBefore,
+		p.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&p.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subscribers returns the users who opted in to reminders on any channel.
+func (r *NotificationRepository) Subscribers(ctx context.Context) ([]*Subscriber, error) {
+	query := `
+	SELECT u.id, u.email, u.time_zone, u.weight_unit, u.distance_unit,
+	np.email_reminders, np.webhook_reminders, np.webhook_url, np.remind_before,
+	np.version
+	FROM notification_preferences AS np
+	JOIN users AS u ON u.id = np.user_id
+	WHERE np.email_reminders OR np.webhook_reminders
+	ORDER BY u.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscribers := []*Subscriber{}
+
+	for rows.Next() {
+		var s Subscriber
+
+		err := rows.Scan(
+			&s.UserID,
+			&s.Email,
+			&s.TimeZone,
+			&s.Units.Weight,
+			&s.Units.Distance,
+			&s.Preferences.EmailReminders,
+			&s.Preferences.WebhookReminders,
+			&s.Preferences.WebhookURL,
+			&s.Preferences.RemindBefore,
+			&s.Preferences.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Preferences.UserID = s.UserID
+		subscribers = append(subscribers, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
+
+// Enqueue adds the job to the queue. It returns false without an error if
+// a job with the same dedupe key was already queued.
+func (r *NotificationRepository) Enqueue(ctx context.Context, job *NotificationJob) (bool, error) {
+	data, err := json.Marshal(job.Data)
+	if err != nil {
+		return false, err
+	}
+
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = 5
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+
+	query := `
+	INSERT INTO notification_jobs(user_id, channel, address, subject, body,
+	data, dedupe_key, run_at, max_attempts)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (dedupe_key) DO NOTHING
+	RETURNING id, status, created_at
+	`
+	args := []any{
+		job.UserID,
+		job.Channel,
+		job.Address,
+		job.Subject,
+		job.Body,
+		data,
+		sql.NullString{String: job.DedupeKey, Valid: job.DedupeKey != ""},
+		job.RunAt,
+		job.MaxAttempts,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.Status, &job.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Claim locks up to limit due jobs for sending for the duration of lease.
+// Jobs whose lease expired, e.g. because the instance sending them
+// crashed, are claimed again while they have attempts left and marked as
+// failed otherwise. Several instances can claim concurrently without
+// getting the same jobs.
+func (r *NotificationRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*NotificationJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+	UPDATE notification_jobs
+	SET status = 'failed', locked_until = NULL,
+	last_error = 'lease expired on the last attempt'
+	WHERE status = 'sending' AND locked_until < NOW()
+	AND attempts >= max_attempts
+	`
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	query = `
+	UPDATE notification_jobs
+	SET status = 'sending', attempts = attempts + 1,
+	locked_until = NOW() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM notification_jobs
+		WHERE (status = 'pending' AND run_at <= NOW())
+		OR (status = 'sending' AND locked_until < NOW() AND attempts < max_attempts)
+		ORDER BY run_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, user_id, channel, address, subject, body, data, status,
+	run_at, attempts, max_attempts, last_error, created_at, sent_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationJobs(rows, true)
+}
+
+// MarkSent records that the job was delivered.
+func (r *NotificationRepository) MarkSent(ctx context.Context, job *NotificationJob) error {
+	query := `
+	UPDATE notification_jobs
+	SET status = 'sent', sent_at = NOW(), locked_until = NULL, last_error = ''
+	WHERE id = $1
+	RETURNING status, sent_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, job.ID).Scan(&job.Status, &job.SentAt)
+}
+
+// MarkCancelled records that the job won't be sent.
+func (r *NotificationRepository) MarkCancelled(ctx context.Context, job *NotificationJob) error {
+	query := `
+	UPDATE notification_jobs
+	SET status = 'cancelled', locked_until = NULL
+	WHERE id = $1
+	RETURNING status
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, job.ID).Scan(&job.Status)
+}
+
+// MarkFailed records the error of the last attempt. The job is retried at
+// retryAt unless it has no attempts left.
+func (r *NotificationRepository) MarkFailed(ctx context.Context, job *NotificationJob, sendErr error, retryAt time.Time) error {
+	job.Status = NotificationPending
+	if job.Attempts >= job.MaxAttempts {
+		job.Status = NotificationFailed
+	}
+
+	job.LastError = sendErr.Error()
+	job.RunAt = retryAt
+
+	query := `
+	UPDATE notification_jobs
+	SET status = $1, last_error = $2, run_at = $3, locked_until = NULL
+	WHERE id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, job.Status, job.LastError, job.RunAt, job.ID)
+
+	return err
+}
+
+// GetAll returns the most recent notifications of the user.
+func (r *NotificationRepository) GetAll(ctx context.Context, userID, limit int) ([]*NotificationJob, error) {
+	query := `
+	SELECT id, user_id, channel, subject, body, data, status, run_at,
+	attempts, max_attempts, last_error, created_at, sent_at
+	FROM notification_jobs
+	WHERE user_id = $1
+	ORDER BY run_at DESC, id DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationJobs(rows, false)
+}
+
+// scanNotificationJobs reads the rows of notification jobs, withAddress
+// tells whether the address was selected.
+func scanNotificationJobs(rows *sql.Rows, withAddress bool) ([]*NotificationJob, error) {
+	jobs := []*NotificationJob{}
+
+	for rows.Next() {
+		var job NotificationJob
+		var data []byte
+
+		dest := []any{&job.ID, &job.UserID, &job.Channel}
+		if withAddress {
+			dest = append(dest, &job.Address)
+		}
+		dest = append(dest,
+			&job.Subject,
+			&job.Body,
+			&data,
+			&job.Status,
+			&job.RunAt,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.LastError,
+			&job.CreatedAt,
+			&job.SentAt,
+		)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &job.Data); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowScript is a token bucket implemented as GCRA, the key holds the
+// theoretical arrival time of the next request in seconds. Replicas share
+// the bucket as the time is read from Redis rather than the caller.
+var allowScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local tat = math.max(tonumber(redis.call("GET", KEYS[1])) or now, now)
+
+local allow_at = tat - (burst - 1) * interval
+if now < allow_at then
+	return {0, 0, tostring(allow_at - now), tostring(tat - now)}
+end
+
+tat = tat + interval
+redis.call("SET", KEYS[1], tostring(tat), "PX", math.ceil((tat - now) * 1000))
+
+return {1, math.floor(burst - (tat - now) / interval), "0", tostring(tat - now)}
+`)
+
+// RateLimit is the outcome of taking a request from a bucket.
+type RateLimit struct {
+	Allowed   bool
+	Remaining int
+
+	// RetryAfter is how long to wait before the next request is allowed,
+	// 0 when it was allowed
+	RetryAfter time.Duration
+
+	// ResetAfter is how long it takes for the bucket to be full again
+	ResetAfter time.Duration
+}
+
+type RateLimitRepository struct {
+	redis   *redis.Client
+	timeout time.Duration
+}
+
+// Allow takes a request from the bucket of key, refilled with rps requests
+// per second up to burst requests.
+func (r *RateLimitRepository) Allow(ctx context.Context, key string, rps float64, burst int) (*RateLimit, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	res, err := allowScript.Run(ctx, r.redis, []string{"rate_limit:" + key}, 1/rps, burst).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script on redis: %w", err)
+	}
+
+	retryAfter, err := strconv.ParseFloat(res[2].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	resetAfter, err := strconv.ParseFloat(res[3].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimit{
+		Allowed:    res[0].(int64) == 1,
+		Remaining:  int(res[1].(int64)),
+		RetryAfter: time.Duration(retryAfter * float64(time.Second)),
+		ResetAfter: time.Duration(resetAfter * float64(time.Second)),
+	}, nil
+}
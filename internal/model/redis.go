@@ -3,23 +3,62 @@ package model
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/ahmadabdelrazik/jasad/internal/metrics"
+	"github.com/ahmadabdelrazik/jasad/pkg/config"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 var ErrNoRedisKey = errors.New("key not found in redis")
 
-func newRedisClient() (*redis.Client, error) {
+func newRedisClient(ctx context.Context, cfg config.Config) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
 	})
 
-	_, err := client.Ping(context.Background()).Result()
+	_, err := client.Ping(ctx).Result()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, err
+	}
+
+	client.AddHook(metricsHook{})
+
 	return client, nil
 }
+
+// metricsHook observes the latency of Redis commands.
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+
+		metrics.RedisDuration.WithLabelValues(cmd.Name()).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		metrics.RedisDuration.WithLabelValues("pipeline").Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
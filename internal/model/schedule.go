@@ -0,0 +1,468 @@
+package model
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/rrule"
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+type OccurrenceStatus string
+
+const (
+	OccurrencePlanned   OccurrenceStatus = "planned"
+	OccurrenceCompleted                  = "completed"
+	OccurrenceSkipped                    = "skipped"
+)
+
+func GetOccurrenceStatus(s string) (OccurrenceStatus, error) {
+	switch s {
+	case "planned":
+		return OccurrencePlanned, nil
+	case "completed":
+		return OccurrenceCompleted, nil
+	case "skipped":
+		return OccurrenceSkipped, nil
+	default:
+		return "", errors.New("invalid occurrence status")
+	}
+}
+
+var clockRX = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// Schedule plans a workout on a date, repeated according to its recurrence
+// rule if it has one.
+type Schedule struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"-"`
+	WorkoutID   int    `json:"workout_id"`
+	WorkoutName string `json:"workout_name"`
+	StartDate   Date   `json:"start_date"`
+
+	// Time of day formatted as "15:04" in the user's time zone, empty for
+	// all day.
+	Time string `json:"time,omitempty"`
+
+	// RRule repeats the schedule, e.g. "FREQ=WEEKLY;BYDAY=MO,WE,FR". Empty
+	// for a single occurrence on the start date.
+	RRule string `json:"rrule,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"-"`
+}
+
+func (s Schedule) Validate(v *validator.Validator) {
+	v.Check(s.WorkoutID > 0, "workout_id", "must be provided")
+
+	v.Check(!s.StartDate.IsZero(), "start_date", "must be provided")
+	v.Check(s.StartDate.Year() >= 2000 && s.StartDate.Year() <= 2100, "start_date", "must be between 2000 and 2100")
+
+	v.Check(s.Time == "" || clockRX.MatchString(s.Time), "time", "must be a time of day in the form of HH:MM")
+
+	if s.RRule != "" {
+		_, err := rrule.Parse(s.RRule)
+		v.Check(err == nil, "rrule", "invalid recurrence rule")
+	}
+}
+
+// Dates returns the dates the schedule occurs on within [from, to].
+func (s Schedule) Dates(from, to Date) []Date {
+	if s.RRule == "" {
+		if s.StartDate.Before(from.Time) || s.StartDate.After(to.Time) {
+			return nil
+		}
+		return []Date{s.StartDate}
+	}
+
+	rule, err := rrule.Parse(s.RRule)
+	if err != nil {
+		return nil
+	}
+
+	times := rule.Between(s.StartDate.Time, from.Time, to.Time)
+
+	dates := make([]Date, len(times))
+	for i, t := range times {
+		dates[i] = Date{t}
+	}
+
+	return dates
+}
+
+// OccursOn reports whether the schedule has an occurrence on the date.
+func (s Schedule) OccursOn(date Date) bool {
+	return len(s.Dates(date, date)) == 1
+}
+
+// Occurrence is a single planned workout of a schedule.
+type Occurrence struct {
+	ScheduleID  int    `json:"schedule_id"`
+	WorkoutID   int    `json:"workout_id"`
+	WorkoutName string `json:"workout_name"`
+	Date        Date   `json:"date"`
+	Time        string `json:"time,omitempty"`
+
+	// StartsAt is set when the schedule has a time of day.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+
+	Status OccurrenceStatus `json:"status"`
+}
+
+type ScheduleRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, s *Schedule) error {
+	query := `
+	INSERT INTO schedules(user_id, workout_id, start_date, time_of_day, rrule)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version
+	`
+	args := []any{s.UserID, s.WorkoutID, s.StartDate, s.Time, s.RRule}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, args...).Scan(&s.ID, &s.CreatedAt, &s.Version)
+}
+
+func (r *ScheduleRepository) Get(ctx context.Context, userID, id int) (*Schedule, error) {
+	query := `
+	SELECT s.id, s.user_id, s.workout_id, w.name, s.start_date, s.time_of_day,
+	s.rrule, s.created_at, s.version
+	FROM schedules AS s
+	JOIN workouts AS w ON w.id = s.workout_id
+	WHERE s.id = $1 AND s.user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	s, err := scanSchedule(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// GetAll returns the schedules of the user ordered by their start date.
+func (r *ScheduleRepository) GetAll(ctx context.Context, userID int) ([]*Schedule, error) {
+	query := `
+	SELECT s.id, s.user_id, s.workout_id, w.name, s.start_date, s.time_of_day,
+	s.rrule, s.created_at, s.version
+	FROM schedules AS s
+	JOIN workouts AS w ON w.id = s.workout_id
+	WHERE s.user_id = $1
+	ORDER BY s.start_date, s.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*Schedule{}
+
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) Update(ctx context.Context, s *Schedule) error {
+	query := `
+	UPDATE schedules
+	SET workout_id = $1, start_date = $2, time_of_day = $3, rrule = $4,
+	version = version + 1
+	WHERE id = $5 AND user_id = $6 AND version = $7
+	RETURNING version
+	`
+	args := []any{s.WorkoutID, s.StartDate, s.Time, s.RRule, s.ID, s.UserID, s.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&s.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) Delete(ctx context.Context, userID, id int) error {
+	query := `
+	DELETE FROM schedules
+	WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetStatus marks the occurrence of the schedule on the date as completed
+// or skipped, or back to planned.
+func (r *ScheduleRepository) SetStatus(ctx context.Context, scheduleID int, date Date, status OccurrenceStatus) error {
+	query := `
+	INSERT INTO schedule_occurrences(schedule_id, occurs_on, status)
+	VALUES($1, $2, $3)
+	ON CONFLICT (schedule_id, occurs_on)
+	DO UPDATE SET status = EXCLUDED.status, updated_at = NOW()
+	`
+	args := []any{scheduleID, date, status}
+
+	// planned is the status of occurrences that weren't marked
+	if status == OccurrencePlanned {
+		query = `
+		DELETE FROM schedule_occurrences
+		WHERE schedule_id = $1 AND occurs_on = $2
+		`
+		args = args[:2]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+// Occurrences returns the planned workouts of the user within [from, to]
+// ordered by date and time. Start times are in loc.
+func (r *ScheduleRepository) Occurrences(ctx context.Context, userID int, from, to Date, loc *time.Location) ([]*Occurrence, error) {
+	schedules, err := r.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := r.statuses(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []*Occurrence{}
+
+	for _, s := range schedules {
+		for _, date := range s.Dates(from, to) {
+			o := &Occurrence{
+				ScheduleID:  s.ID,
+				WorkoutID:   s.WorkoutID,
+				WorkoutName: s.WorkoutName,
+				Date:        date,
+				Time:        s.Time,
+				Status:      OccurrencePlanned,
+			}
+
+			if status, ok := statuses[occurrenceKey{s.ID, date.String()}]; ok {
+				o.Status = status
+			}
+
+			if s.Time != "" {
+				startsAt := date.At(s.Time, loc)
+				o.StartsAt = &startsAt
+			}
+
+			occurrences = append(occurrences, o)
+		}
+	}
+
+	slices.SortStableFunc(occurrences, func(a, b *Occurrence) int {
+		return cmp.Or(a.Date.Compare(b.Date.Time), cmp.Compare(a.Time, b.Time))
+	})
+
+	return occurrences, nil
+}
+
+type occurrenceKey struct {
+	scheduleID int
+	date       string
+}
+
+// statuses returns the statuses of the user's occurrences marked within
+// [from, to].
+func (r *ScheduleRepository) statuses(ctx context.Context, userID int, from, to Date) (map[occurrenceKey]OccurrenceStatus, error) {
+	query := `
+	SELECT o.schedule_id, o.occurs_on, o.status
+	FROM schedule_occurrences AS o
+	JOIN schedules AS s ON s.id = o.schedule_id
+	WHERE s.user_id = $1 AND o.occurs_on BETWEEN $2 AND $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := map[occurrenceKey]OccurrenceStatus{}
+
+	for rows.Next() {
+		var scheduleID int
+		var date Date
+		var status OccurrenceStatus
+
+		if err := rows.Scan(&scheduleID, &date, &status); err != nil {
+			return nil, err
+		}
+
+		statuses[occurrenceKey{scheduleID, date.String()}] = status
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// CreateFeed returns a new token for the user's calendar feed, replacing
+// the previous one.
+func (r *ScheduleRepository) CreateFeed(ctx context.Context, userID int) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO calendar_feeds(hash, user_id) VALUES($1, $2)`, hash[:], userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetFeedOwner returns the id of the user the feed token belongs to.
+func (r *ScheduleRepository) GetFeedOwner(ctx context.Context, token string) (int, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `
+	SELECT user_id
+	FROM calendar_feeds
+	WHERE hash = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var userID int
+
+	err := r.db.QueryRowContext(ctx, query, hash[:]).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// DeleteFeed revokes the user's calendar feed.
+func (r *ScheduleRepository) DeleteFeed(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func scanSchedule(row interface{ Scan(...any) error }) (*Schedule, error) {
+	var s Schedule
+
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.WorkoutID,
+		&s.WorkoutName,
+		&s.StartDate,
+		&s.Time,
+		&s.RRule,
+		&s.CreatedAt,
+		&s.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
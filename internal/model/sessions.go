@@ -8,14 +8,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/ahmadabdelrazik/jasad/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
 type Session struct {
-	UserID int
-	Role   Role
+	UserID    int
+	Role      Role
+	CreatedAt time.Time
+	ExpiresAt time.Time
 }
 
 // For Redis client to be able to marshal it.
@@ -24,40 +28,166 @@ func (s Session) MarshalBinary() ([]byte, error) {
 }
 
 type TokenRepository struct {
-	redis *redis.Client
+	redis   *redis.Client
+	timeout time.Duration
+
+	// ttl is how long a session stays valid after logging in
+	ttl time.Duration
 }
 
-// GenerateToken returns session token for the given user.
-func (r *TokenRepository) GenerateToken(user *User) (string, error) {
+// newToken returns a random unguessable token along with its hash. Only the
+// hash is meant to be stored, the token itself is handed to the client.
+func newToken() (string, [32]byte, error) {
 	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+		return "", [32]byte{}, fmt.Errorf("failed to generate random bytes: %w", err)
 	}
 
 	token := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes)
 
-	hash := sha256.Sum256([]byte(token))
+	return token, sha256.Sum256([]byte(token)), nil
+}
+
+// GenerateToken returns session token for the given user.
+func (r *TokenRepository) GenerateToken(ctx context.Context, user *User) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 
 	session := &Session{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Role:      user.Role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(r.ttl),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	err := r.redis.Set(ctx, string(hash[:]), session, 3*24*time.Hour).Err()
+	// keep track of the user's sessions so they can be listed and revoked
+	// all at once.
+	_, err = r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, string(hash[:]), session, r.ttl)
+		pipe.SAdd(ctx, userSessionsKey(user.ID), string(hash[:]))
+		pipe.Expire(ctx, userSessionsKey(user.ID), r.ttl)
+		pipe.ZAdd(ctx, activeSessionsKey, redis.Z{
+			Score:  float64(session.ExpiresAt.Unix()),
+			Member: string(hash[:]),
+		})
+		return nil
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to set value on redis: %w", err)
 	}
 
+	metrics.SessionsCreated.Inc()
+
 	return token, nil
 }
 
-func (r *TokenRepository) GetSessionFromToken(token string) (*Session, error) {
+// activeSessionsKey is the key of the sorted set holding the hashes of all
+// sessions scored by their expiry, so they can be counted without scanning
+// the keys of every user.
+const activeSessionsKey = "active_sessions"
+
+// userSessionsKey is the key of the set holding the hashes of the user's
+// sessions.
+func userSessionsKey(userID int) string {
+	return fmt.Sprintf("user_sessions:%d", userID)
+}
+
+// GetUserSessions returns the active sessions of the user.
+func (r *TokenRepository) GetUserSessions(ctx context.Context, userID int) ([]*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	hashes, err := r.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value on redis: %w", err)
+	}
+
+	sessions := []*Session{}
+
+	for _, hash := range hashes {
+		sessionStr, err := r.redis.Get(ctx, hash).Result()
+		if err != nil {
+			switch {
+			case errors.Is(err, redis.Nil): // expired session
+				r.redis.SRem(ctx, userSessionsKey(userID), hash)
+				continue
+			default:
+				return nil, fmt.Errorf("failed to get value on redis: %w", err)
+			}
+		}
+
+		var session Session
+		if err := json.Unmarshal([]byte(sessionStr), &session); err != nil {
+			return nil, fmt.Errorf("failed to marshal session: %w", err)
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
+}
+
+// CountSessions returns the number of sessions of users signed in, dropping
+// the expired ones from the count.
+func (r *TokenRepository) CountSessions(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count *redis.IntCmd
+
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, activeSessionsKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+		count = pipe.ZCard(ctx, activeSessionsKey)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get value on redis: %w", err)
+	}
+
+	return count.Val(), nil
+}
+
+// RevokeUserSessions logs the user out of all of their sessions.
+func (r *TokenRepository) RevokeUserSessions(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	hashes, err := r.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get value on redis: %w", err)
+	}
+
+	keys := append(hashes, userSessionsKey(userID))
+
+	_, err = r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		if len(hashes) > 0 {
+			members := make([]any, len(hashes))
+			for i, hash := range hashes {
+				members[i] = hash
+			}
+			pipe.ZRem(ctx, activeSessionsKey, members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete value on redis: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TokenRepository) GetSessionFromToken(ctx context.Context, token string) (*Session, error) {
 	hash := sha256.Sum256([]byte(token))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	sessionStr, err := r.redis.Get(ctx, string(hash[:])).Result()
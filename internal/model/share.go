@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// WorkoutShare is a public link to a workout. Anyone holding the token can
+// view the workout and copy it to their own account until the share expires
+// or gets revoked by the owner.
+type WorkoutShare struct {
+	ID        int `json:"id"`
+	WorkoutID int `json:"workout_id"`
+	OwnerID   int `json:"-"`
+
+	// Token is only available right after creating the share, we only keep
+	// its hash in the database.
+	Token string `json:"token,omitempty"`
+
+	// ExpiresAt is nil for shares that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Revoked   bool       `json:"revoked"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (s WorkoutShare) Validate(v *validator.Validator) {
+	if s.ExpiresAt != nil {
+		v.Check(s.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+		v.Check(s.ExpiresAt.Before(time.Now().AddDate(1, 0, 0)), "expires_at", "must be within a year")
+	}
+}
+
+type ShareRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// Create generates a new token for the share and stores its hash.
+func (r *ShareRepository) Create(ctx context.Context, share *WorkoutShare) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO workout_shares(hash, workout_id, expires_at)
+	VALUES($1, $2, $3)
+	RETURNING id, created_at
+	`
+	args := []any{hash[:], share.WorkoutID, share.ExpiresAt}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(&share.ID, &share.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	share.Token = token
+
+	return nil
+}
+
+// GetByToken returns the share of the given token as long as it has not
+// expired nor been revoked.
+func (r *ShareRepository) GetByToken(ctx context.Context, token string) (*WorkoutShare, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `
+	SELECT s.id, s.workout_id, w.owner_id, s.expires_at, s.revoked, s.created_at
+	FROM workout_shares AS s
+	JOIN workouts AS w ON w.id = s.workout_id
+	WHERE s.hash = $1 AND NOT s.revoked
+	AND (s.expires_at IS NULL OR s.expires_at > NOW())
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var share WorkoutShare
+
+	err := r.db.QueryRowContext(ctx, query, hash[:]).Scan(
+		&share.ID,
+		&share.WorkoutID,
+		&share.OwnerID,
+		&share.ExpiresAt,
+		&share.Revoked,
+		&share.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &share, nil
+}
+
+// GetAll returns the shares of the owner's workouts. If workoutID is not
+// zero only the shares of that workout are returned.
+func (r *ShareRepository) GetAll(ctx context.Context, ownerID, workoutID int) ([]*WorkoutShare, error) {
+	query := `
+	SELECT s.id, s.workout_id, w.owner_id, s.expires_at, s.revoked, s.created_at
+	FROM workout_shares AS s
+	JOIN workouts AS w ON w.id = s.workout_id
+	WHERE w.owner_id = $1 AND (s.workout_id = $2 OR $2 = 0)
+	ORDER BY s.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, ownerID, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*WorkoutShare{}
+
+	for rows.Next() {
+		var share WorkoutShare
+
+		err := rows.Scan(
+			&share.ID,
+			&share.WorkoutID,
+			&share.OwnerID,
+			&share.ExpiresAt,
+			&share.Revoked,
+			&share.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, &share)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// Revoke disables the share so its token can no longer be used.
+func (r *ShareRepository) Revoke(ctx context.Context, ownerID, shareID int) error {
+	query := `
+	UPDATE workout_shares AS s SET revoked = TRUE
+	FROM workouts AS w
+	WHERE w.id = s.workout_id AND s.id = $1 AND w.owner_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, shareID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+)
+
+// WorkoutTemplate is a public workout curated by admins that users can copy
+// into their own workouts. The workout itself is stored like any other
+// workout but without an owner, so it's only reachable through its template.
+type WorkoutTemplate struct {
+	ID          int       `json:"id"`
+	Description string    `json:"description"`
+	Goal        Goal      `json:"goal"`
+	Level       Level     `json:"level"`
+	Workout     *Workout  `json:"workout"`
+	CreatedAt   time.Time `json:"created_at"`
+	Version     int       `json:"-"`
+}
+
+func (t WorkoutTemplate) Validate(v *validator.Validator) {
+	v.Check(strings.Trim(t.Description, " ") != "", "description", "can't be empty")
+	v.Check(len(t.Description) < 1000, "description", "must be less than 1000 bytes")
+
+	if t.Workout == nil {
+		panic("template without a workout")
+	}
+	t.Workout.Validate(v)
+}
+
+type TemplateRepository struct {
+	db       *sql.DB
+	workouts *WorkoutRepository
+	timeout  time.Duration
+}
+
+func (r *TemplateRepository) Create(ctx context.Context, template *WorkoutTemplate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("can't start transaction: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := r.workouts.insert(ctx, tx, template.Workout); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `
+	INSERT INTO workout_templates(workout_id, description, goal, level)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at, version
+	`
+	args := []any{
+		template.Workout.ID,
+		template.Description,
+		template.Goal,
+		template.Level,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&template.ID,
+		&template.CreatedAt,
+		&template.Version,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error inserting to workout_templates: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (r *TemplateRepository) Get(ctx context.Context, id int) (*WorkoutTemplate, error) {
+	query := `
+	SELECT t.id, t.description, t.goal, t.level, t.created_at, t.version,
+	w.id, w.name, w.created_at, w.version
+	FROM workout_templates AS t
+	JOIN workouts AS w ON w.id = t.workout_id
+	WHERE t.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var template WorkoutTemplate
+	var workout Workout
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&template.ID,
+		&template.Description,
+		&template.Goal,
+		&template.Level,
+		&template.CreatedAt,
+		&template.Version,
+		&workout.ID,
+		&workout.Name,
+		&workout.CreatedAt,
+		&workout.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := r.workouts.loadExercises(ctx, []*Workout{&workout}); err != nil {
+		return nil, err
+	}
+
+	template.Workout = &workout
+
+	return &template, nil
+}
+
+// Search returns a page of templates matching the given parameters, empty
+// values disable the corresponding filter.
+func (r *TemplateRepository) Search(ctx context.Context, name, goal, level, muscle string, filters Filters) ([]*WorkoutTemplate, Metadata, error) {
+	// output columns are aliased so the sort column can be referenced
+	// without a table prefix.
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) OVER(), t.id AS id, t.description, t.goal, t.level,
+	t.created_at AS created_at, t.version, w.id AS workout_id,
+	w.name AS name, w.created_at AS workout_created_at,
+	w.version AS workout_version
+	FROM workout_templates AS t
+	JOIN workouts AS w ON w.id = t.workout_id
+	WHERE (to_tsvector('simple', w.name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	AND (t.goal = $2 OR $2 = '')
+	AND (t.level = $3 OR $3 = '')
+	AND ($4 = '' OR EXISTS (
+		SELECT 1 FROM workouts_exercises AS we
+		JOIN exercises AS e ON e.id = we.exercise_id
+		WHERE we.workout_id = w.id AND e.muscle = $4
+	))
+	ORDER BY %s %s, id ASC
+	LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	args := []any{name, goal, level, muscle, filters.limit(), filters.offset()}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	templates := []*WorkoutTemplate{}
+	workouts := []*Workout{}
+
+	for rows.Next() {
+		var template WorkoutTemplate
+		var workout Workout
+
+		err := rows.Scan(
+			&totalRecords,
+			&template.ID,
+			&template.Description,
+			&template.Goal,
+			&template.Level,
+			&template.CreatedAt,
+			&template.Version,
+			&workout.ID,
+			&workout.Name,
+			&workout.CreatedAt,
+			&workout.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		template.Workout = &workout
+		templates = append(templates, &template)
+		workouts = append(workouts, &workout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if err := r.workouts.loadExercises(ctx, workouts); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return templates, metadata, nil
+}
+
+func (r *TemplateRepository) Update(ctx context.Context, template *WorkoutTemplate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+	UPDATE workout_templates
+	SET description = $1, goal = $2, level = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version
+	`
+	args := []any{
+		template.Description,
+		template.Goal,
+		template.Level,
+		template.ID,
+		template.Version,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&template.Version)
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if err := r.workouts.update(ctx, tx, template.Workout); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the template along with its workout.
+func (r *TemplateRepository) Delete(ctx context.Context, id int) error {
+	query := `
+	DELETE FROM workouts
+	WHERE id = (SELECT workout_id FROM workout_templates WHERE id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
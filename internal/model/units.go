@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"math"
+)
+
+type WeightUnit string
+
+const (
+	Kilograms WeightUnit = "kg"
+	Pounds               = "lb"
+)
+
+func GetWeightUnit(s string) (WeightUnit, error) {
+	switch s {
+	case "kg":
+		return Kilograms, nil
+	case "lb":
+		return Pounds, nil
+	default:
+		return "", errors.New("invalid weight unit")
+	}
+}
+
+type DistanceUnit string
+
+const (
+	Kilometers DistanceUnit = "km"
+	Miles                   = "mi"
+)
+
+func GetDistanceUnit(s string) (DistanceUnit, error) {
+	switch s {
+	case "km":
+		return Kilometers, nil
+	case "mi":
+		return Miles, nil
+	default:
+		return "", errors.New("invalid distance unit")
+	}
+}
+
+// Units are the units a user enters and reads weights and distances in.
+type Units struct {
+	Weight   WeightUnit   `json:"weight"`
+	Distance DistanceUnit `json:"distance"`
+}
+
+var DefaultUnits = Units{Weight: Kilograms, Distance: Kilometers}
+
+const (
+	gramsPerPound   = 453.59237
+	metersPerMile   = 1609.344
+	weightPrecision = 100  // weights are shown with 2 decimal places
+	distPrecision   = 1000 // distances are shown with 3 decimal places
+)
+
+// Weight is stored canonically as a whole number of grams to avoid floating
+// point errors when adding up or converting weights.
+type Weight int64
+
+// NewWeight converts value given in unit to a Weight. Unknown units are
+// treated as kilograms.
+func NewWeight(value float64, unit WeightUnit) Weight {
+	switch unit {
+	case Pounds:
+		return Weight(math.Round(value * gramsPerPound))
+	default:
+		return Weight(math.Round(value * 1000))
+	}
+}
+
+// In returns the weight in the given unit rounded to 2 decimal places.
+// Unknown units are treated as kilograms.
+func (w Weight) In(unit WeightUnit) float64 {
+	var value float64
+
+	switch unit {
+	case Pounds:
+		value = float64(w) / gramsPerPound
+	default:
+		value = float64(w) / 1000
+	}
+
+	return math.Round(value*weightPrecision) / weightPrecision
+}
+
+// Distance is stored canonically as a whole number of meters.
+type Distance int64
+
+// NewDistance converts value given in unit to a Distance. Unknown units are
+// treated as kilometers.
+func NewDistance(value float64, unit DistanceUnit) Distance {
+	switch unit {
+	case Miles:
+		return Distance(math.Round(value * metersPerMile))
+	default:
+		return Distance(math.Round(value * 1000))
+	}
+}
+
+// In returns the distance in the given unit rounded to 3 decimal places.
+// Unknown units are treated as kilometers.
+func (d Distance) In(unit DistanceUnit) float64 {
+	var value float64
+
+	switch unit {
+	case Miles:
+		value = float64(d) / metersPerMile
+	default:
+		value = float64(d) / 1000
+	}
+
+	return math.Round(value*distPrecision) / distPrecision
+}
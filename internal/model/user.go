@@ -10,31 +10,145 @@ import (
 	"github.com/ahmadabdelrazik/jasad/pkg/validator"
 )
 
+type Sex string
+
+const (
+	SexMale   Sex = "male"
+	SexFemale     = "female"
+	SexOther      = "other"
+)
+
+func GetSex(s string) (Sex, error) {
+	switch s {
+	case "male":
+		return SexMale, nil
+	case "female":
+		return SexFemale, nil
+	case "other":
+		return SexOther, nil
+	default:
+		return "", errors.New("invalid sex")
+	}
+}
+
 type User struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Role    Role   `json:"role"`
-	Version int    `json:"-"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  Role   `json:"role"`
+	Profile
+	Version int `json:"-"`
+}
+
+// Profile holds the details and preferences users can edit themselves.
+// Empty values are not set by the user.
+type Profile struct {
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	BirthYear   int    `json:"birth_year,omitempty"`
+	Sex         Sex    `json:"sex,omitempty"`
+	Height      int    `json:"height,omitempty"` // in centimeters
+	Goal        Goal   `json:"goal,omitempty"`
+	Level       Level  `json:"level,omitempty"`
+	TimeZone    string `json:"time_zone"`
+	Units       Units  `json:"units"`
+
+	// DefaultRest is used for workout exercises that don't specify their
+	// rest time, in seconds.
+	DefaultRest int `json:"default_rest,omitempty"`
+}
+
+var DefaultProfile = Profile{
+	TimeZone: "UTC",
+	Units:    DefaultUnits,
 }
 
 func (u User) Validate(v *validator.Validator) {
+	v.Check(len(u.DisplayName) <= 50, "display_name", "must not be more than 50 bytes")
+
+	if u.AvatarURL != "" {
+		v.Check(validator.URLRX.MatchString(u.AvatarURL), "avatar_url", "must be a valid url")
+	}
+
+	if u.BirthYear != 0 {
+		v.Check(u.BirthYear >= 1900, "birth_year", "must be after 1900")
+		v.Check(u.BirthYear <= time.Now().Year()-13, "birth_year", "must be at least 13 years ago")
+	}
 
+	if u.Sex != "" {
+		_, err := GetSex(string(u.Sex))
+		v.Check(err == nil, "sex", "invalid sex")
+	}
+
+	if u.Height != 0 {
+		v.Check(u.Height >= 50, "height", "must be at least 50 cm")
+		v.Check(u.Height <= 300, "height", "must not be more than 300 cm")
+	}
+
+	if u.Goal != "" {
+		_, err := GetGoal(string(u.Goal))
+		v.Check(err == nil, "goal", "invalid goal")
+	}
+
+	if u.Level != "" {
+		_, err := GetLevel(string(u.Level))
+		v.Check(err == nil, "level", "invalid level")
+	}
+
+	_, err := time.LoadLocation(u.TimeZone)
+	v.Check(u.TimeZone != "" && err == nil, "time_zone", "must be a valid IANA time zone")
+
+	_, err = GetWeightUnit(string(u.Units.Weight))
+	v.Check(err == nil, "units.weight", "invalid weight unit")
+
+	_, err = GetDistanceUnit(string(u.Units.Distance))
+	v.Check(err == nil, "units.distance", "invalid distance unit")
+
+	v.Check(u.DefaultRest >= 0, "default_rest", "must be a positive number")
+	v.Check(u.DefaultRest < 15*60, "default_rest", "must be less than 15 minutes")
+}
+
+// Location returns the user's time zone, UTC if it's not set.
+func (p Profile) Location() *time.Location {
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil || p.TimeZone == "" {
+		return time.UTC
+	}
+
+	return loc
 }
 
 type UserRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func (r *UserRepository) Create(user *User) error {
+func (r *UserRepository) Create(ctx context.Context, user *User) error {
 	query := `
-	INSERT INTO users(name, email, role)
-	VALUES($1, $2, $3)
+	INSERT INTO users(name, email, role, display_name, avatar_url, birth_year,
+	sex, height, goal, level, time_zone, weight_unit, distance_unit,
+	default_rest)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	RETURNING id, version
 	`
-	args := []any{user.Name, user.Email, user.Role}
+	args := []any{
+		user.Name,
+		user.Email,
+		user.Role,
+		user.DisplayName,
+		user.AvatarURL,
+		user.BirthYear,
+		user.Sex,
+		user.Height,
+		user.Goal,
+		user.Level,
+		user.TimeZone,
+		user.Units.Weight,
+		user.Units.Distance,
+		user.DefaultRest,
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Version)
@@ -50,9 +164,11 @@ func (r *UserRepository) Create(user *User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByID(id int) (*User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*User, error) {
 	query := `
-	SELECT id, name, email, role, version
+	SELECT id, name, email, role, display_name, avatar_url, birth_year, sex,
+	height, goal, level, time_zone, weight_unit, distance_unit, default_rest,
+	version
 	FROM users
 	WHERE id = $1
 	`
@@ -61,7 +177,7 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 		ID: id,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
@@ -69,6 +185,17 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 		&user.Name,
 		&user.Email,
 		&user.Role,
+		&user.DisplayName,
+		&user.AvatarURL,
+		&user.BirthYear,
+		&user.Sex,
+		&user.Height,
+		&user.Goal,
+		&user.Level,
+		&user.TimeZone,
+		&user.Units.Weight,
+		&user.Units.Distance,
+		&user.DefaultRest,
 		&user.Version,
 	)
 	if err != nil {
@@ -83,9 +210,11 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-	SELECT id, name, email, role, version
+	SELECT id, name, email, role, display_name, avatar_url, birth_year, sex,
+	height, goal, level, time_zone, weight_unit, distance_unit, default_rest,
+	version
 	FROM users
 	WHERE email = $1
 	`
@@ -94,7 +223,7 @@ func (r *UserRepository) GetByEmail(email string) (*User, error) {
 		Email: email,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
@@ -102,6 +231,17 @@ func (r *UserRepository) GetByEmail(email string) (*User, error) {
 		&user.Name,
 		&user.Email,
 		&user.Role,
+		&user.DisplayName,
+		&user.AvatarURL,
+		&user.BirthYear,
+		&user.Sex,
+		&user.Height,
+		&user.Goal,
+		&user.Level,
+		&user.TimeZone,
+		&user.Units.Weight,
+		&user.Units.Distance,
+		&user.DefaultRest,
 		&user.Version,
 	)
 	if err != nil {
@@ -116,13 +256,15 @@ func (r *UserRepository) GetByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) GetAll() ([]*User, error) {
+func (r *UserRepository) GetAll(ctx context.Context) ([]*User, error) {
 	query := `
-	SELECT id, name, email, role, version
+	SELECT id, name, email, role, display_name, avatar_url, birth_year, sex,
+	height, goal, level, time_zone, weight_unit, distance_unit, default_rest,
+	version
 	FROM users
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -146,11 +288,24 @@ func (r *UserRepository) GetAll() ([]*User, error) {
 			&user.Name,
 			&user.Email,
 			&user.Role,
+			&user.DisplayName,
+			&user.AvatarURL,
+			&user.BirthYear,
+			&user.Sex,
+			&user.Height,
+			&user.Goal,
+			&user.Level,
+			&user.TimeZone,
+			&user.Units.Weight,
+			&user.Units.Distance,
+			&user.DefaultRest,
 			&user.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
+
+		users = append(users, &user)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -159,3 +314,104 @@ func (r *UserRepository) GetAll() ([]*User, error) {
 
 	return users, nil
 }
+
+func (r *UserRepository) Update(ctx context.Context, user *User) error {
+	query := `
+	UPDATE users
+	SET name = $1, email = $2, role = $3, display_name = $4, avatar_url = $5,
+	birth_year = $6, sex = $7, height = $8, goal = $9, level = $10,
+	time_zone = $11, weight_unit = $12, distance_unit = $13,
+	default_rest = $14, version = version + 1
+	WHERE id = $15 AND version = $16
+	RETURNING version
+	`
+	args := []any{
+		user.Name,
+		user.Email,
+		user.Role,
+		user.DisplayName,
+		user.AvatarURL,
+		user.BirthYear,
+		user.Sex,
+		user.Height,
+		user.Goal,
+		user.Level,
+		user.TimeZone,
+		user.Units.Weight,
+		user.Units.Distance,
+		user.DefaultRest,
+		user.ID,
+		user.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the user along with everything they own. Their audit
+// entries are kept but no longer linked to them, along with an entry of
+// the deletion.
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&id); err != nil {
+		tx.Rollback()
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `UPDATE audit_log SET user_id = NULL, details = '{}' WHERE user_id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query = `INSERT INTO audit_log(user_id, action, details) VALUES(NULL, $1, '{}')`
+
+	if _, err := tx.ExecContext(ctx, query, AuditAccountDeleted); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// workouts, their exercises and everything else owned by the user are
+	// removed by the cascading foreign keys.
+	query = `DELETE FROM users WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
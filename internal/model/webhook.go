@@ -0,0 +1,508 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+	"github.com/lib/pq"
+)
+
+// Events webhooks can subscribe to.
+const (
+	EventWorkoutCreated   = "workout.created"
+	EventSessionCompleted = "session.completed"
+	EventRecordAchieved   = "record.achieved"
+	EventExerciseUpdated  = "exercise.updated"
+)
+
+// SessionCompleted is the data of session.completed events, emitted when
+// all the exercises of a workout are done or when a planned occurrence of a
+// schedule is marked completed. ScheduleID is only set for the latter.
+type SessionCompleted struct {
+	WorkoutID   int    `json:"workout_id"`
+	WorkoutName string `json:"workout_name"`
+	ScheduleID  *int   `json:"schedule_id"`
+	Date        Date   `json:"date"` // in the user's time zone
+}
+
+var WebhookEvents = []string{
+	EventWorkoutCreated,
+	EventSessionCompleted,
+	EventRecordAchieved,
+	EventExerciseUpdated,
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySending                  = "sending"
+	DeliverySucceeded                = "succeeded"
+	DeliveryFailed                   = "failed"
+)
+
+// Webhook subscribes a url to events. Users receive the events of their own
+// data and of the catalog, global webhooks managed by admins receive the
+// events of every user.
+type Webhook struct {
+	ID int `json:"id"`
+
+	// UserID is 0 for global webhooks
+	UserID int  `json:"-"`
+	Global bool `json:"global"`
+
+	URL string `json:"url"`
+
+	// Secret signs the deliveries, it's only shown when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"-"`
+}
+
+func (wh Webhook) Validate(v *validator.Validator) {
+	v.Check(validator.HTTPURLRX.MatchString(wh.URL), "url", "must be a valid http or https url")
+	v.Check(len(wh.URL) <= 500, "url", "must not be more than 500 bytes")
+
+	v.Check(len(wh.Events) > 0, "events", "must include at least one event")
+	for _, event := range wh.Events {
+		v.Check(slices.Contains(WebhookEvents, event), "events", "invalid event "+event)
+	}
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook.
+type WebhookDelivery struct {
+	ID        int64           `json:"id"`
+	WebhookID int             `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    DeliveryStatus  `json:"status"`
+	RunAt     time.Time       `json:"run_at"`
+	Attempts  int             `json:"attempts"`
+
+	// status code of the last response, 0 if there was none
+	ResponseStatus int    `json:"response_status,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+
+	MaxAttempts int `json:"-"`
+
+	// of the webhook, set by Claim.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// Create generates a secret for the webhook and saves it.
+func (r *WebhookRepository) Create(ctx context.Context, wh *Webhook) error {
+	secret, _, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	wh.Secret = "whsec_" + secret
+
+	query := `
+	INSERT INTO webhooks(user_id, url, secret, events, active)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version
+	`
+	args := []any{
+		sql.NullInt64{Int64: int64(wh.UserID), Valid: !wh.Global},
+		wh.URL,
+		wh.Secret,
+		pq.Array(wh.Events),
+		wh.Active,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx, query, args...).Scan(&wh.ID, &wh.CreatedAt, &wh.Version)
+}
+
+func (r *WebhookRepository) Get(ctx context.Context, id int) (*Webhook, error) {
+	query := `
+	SELECT id, user_id, url, events, active, created_at, version
+	FROM webhooks
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	wh, err := scanWebhook(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return wh, nil
+}
+
+// GetAll returns the webhooks of the user, along with the global ones if
+// withGlobal is set.
+func (r *WebhookRepository) GetAll(ctx context.Context, userID int, withGlobal bool) ([]*Webhook, error) {
+	query := `
+	SELECT id, user_id, url, events, active, created_at, version
+	FROM webhooks
+	WHERE user_id = $1 OR ($2 AND user_id IS NULL)
+	ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, userID, withGlobal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, wh)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, wh *Webhook) error {
+	query := `
+	UPDATE webhooks
+	SET url = $1, events = $2, active = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version
+	`
+	args := []any{wh.URL, pq.Array(wh.Events), wh.Active, wh.ID, wh.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&wh.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of the event to every active webhook
+// subscribed to it, global webhooks and the ones of the user. Catalog
+// events have no user, they're queued to every subscribed webhook.
+func (r *WebhookRepository) Enqueue(ctx context.Context, event string, userID int, payload []byte) error {
+	query := `
+	INSERT INTO webhook_deliveries(webhook_id, event, payload)
+	SELECT id, $1, $2
+	FROM webhooks
+	WHERE active AND $1 = ANY(events)
+	AND (user_id IS NULL OR user_id = $3 OR $3 = 0)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, event, payload, userID)
+
+	return err
+}
+
+// Claim locks up to limit due deliveries of active webhooks for sending for
+// the duration of lease, see NotificationRepository.Claim.
+func (r *WebhookRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'failed', locked_until = NULL,
+	last_error = 'lease expired on the last attempt'
+	WHERE status = 'sending' AND locked_until < NOW()
+	AND attempts >= max_attempts
+	`
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	query = `
+	UPDATE webhook_deliveries AS d
+	SET status = 'sending', attempts = d.attempts + 1,
+	locked_until = NOW() + make_interval(secs => $2)
+	FROM webhooks AS w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT dd.id FROM webhook_deliveries AS dd
+		JOIN webhooks AS ww ON ww.id = dd.webhook_id
+		WHERE ww.active
+		AND ((dd.status = 'pending' AND dd.run_at <= NOW())
+		OR (dd.status = 'sending' AND dd.locked_until < NOW() AND dd.attempts < dd.max_attempts))
+		ORDER BY dd.run_at
+		LIMIT $1
+		FOR UPDATE OF dd SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.run_at,
+	d.attempts, d.response_status, d.last_error, d.created_at, d.delivered_at,
+	d.max_attempts, w.url, w.secret
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var d WebhookDelivery
+
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.RunAt,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+			&d.MaxAttempts,
+			&d.URL,
+			&d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered records the successful response of the delivery.
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, d *WebhookDelivery, responseStatus int) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'succeeded', response_status = $1, last_error = '',
+	locked_until = NULL, delivered_at = NOW()
+	WHERE id = $2
+	RETURNING status, delivered_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	d.ResponseStatus = responseStatus
+
+	return r.db.QueryRowContext(ctx, query, responseStatus, d.ID).Scan(&d.Status, &d.DeliveredAt)
+}
+
+// MarkFailed records the error of the last attempt. The delivery is
+// retried at retryAt unless it has no attempts left.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, d *WebhookDelivery, responseStatus int, sendErr error, retryAt time.Time) error {
+	d.Status = DeliveryPending
+	if d.Attempts >= d.MaxAttempts {
+		d.Status = DeliveryFailed
+	}
+
+	d.ResponseStatus = responseStatus
+	d.LastError = sendErr.Error()
+	d.RunAt = retryAt
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1, response_status = $2, last_error = $3, run_at = $4,
+	locked_until = NULL
+	WHERE id = $5
+	`
+	args := []any{d.Status, d.ResponseStatus, d.LastError, d.RunAt, d.ID}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+// Deliveries returns the latest deliveries of the webhook.
+func (r *WebhookRepository) Deliveries(ctx context.Context, webhookID int, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := `
+	SELECT COUNT(*) OVER(), id, webhook_id, event, payload, status, run_at,
+	attempts, response_status, last_error, created_at, delivered_at,
+	max_attempts
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, webhookID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var d WebhookDelivery
+
+		err := rows.Scan(
+			&totalRecords,
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.RunAt,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+			&d.MaxAttempts,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// Replay queues the delivery of the webhook again with the same payload as
+// a new delivery, keeping the original one in the log.
+func (r *WebhookRepository) Replay(ctx context.Context, webhookID int, deliveryID int64) (*WebhookDelivery, error) {
+	query := `
+	INSERT INTO webhook_deliveries(webhook_id, event, payload)
+	SELECT webhook_id, event, payload
+	FROM webhook_deliveries
+	WHERE id = $1 AND webhook_id = $2
+	RETURNING id, webhook_id, event, payload, status, run_at, attempts,
+	response_status, last_error, created_at, delivered_at, max_attempts
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var d WebhookDelivery
+
+	err := r.db.QueryRowContext(ctx, query, deliveryID, webhookID).Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.RunAt,
+		&d.Attempts,
+		&d.ResponseStatus,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+		&d.MaxAttempts,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (*Webhook, error) {
+	var wh Webhook
+	var userID sql.NullInt64
+
+	err := row.Scan(
+		&wh.ID,
+		&userID,
+		&wh.URL,
+		pq.Array(&wh.Events),
+		&wh.Active,
+		&wh.CreatedAt,
+		&wh.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.UserID = int(userID.Int64)
+	wh.Global = !userID.Valid
+
+	return &wh, nil
+}
@@ -3,19 +3,24 @@ package model
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ahmadabdelrazik/jasad/pkg/validator"
+	"github.com/lib/pq"
 )
 
 type Workout struct {
 	ID                int               `json:"id"`
-	OwnerID           int               `json:"-"`
+	OwnerID           int               `json:"-"` // 0 for the workouts of templates
 	Name              string            `json:"name"`
 	Exercises         []WorkoutExercise `json:"exercises"`
+	Blocks            []WorkoutBlock    `json:"blocks,omitempty"`
 	NumberOfExercises int               `json:"number_of_exercises,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
 	Version           int               `json:"-"`
 }
 
@@ -27,24 +32,135 @@ func (w Workout) Validate(v *validator.Validator) {
 		panic("number of exercises does not match with the actual exercises")
 	}
 
-	for i, exercise := range w.Exercises {
-		v.Check(i+1 == exercise.Order, "order", "exercises are not ordered correctly")
+	orders := make(map[int]bool, len(w.Exercises))
+
+	for _, exercise := range w.Exercises {
+		v.Check(exercise.Order >= 1 && exercise.Order <= len(w.Exercises), "order", "exercises are not ordered correctly")
+		v.Check(!orders[exercise.Order], "order", "exercises must have unique orders")
+		orders[exercise.Order] = true
+
 		exercise.Validate(v)
 	}
+
+	validateBlocks(v, w.Blocks, w.Exercises)
+}
+
+// SetUnits sets the units the workout's weights and distances are presented
+// in. Entries with their own weight unit keep using it.
+func (w *Workout) SetUnits(units Units) {
+	for i := range w.Exercises {
+		w.Exercises[i].units = units
+	}
+}
+
+// SetBodyweight sets the bodyweight used to calculate the load and relative
+// strength of the workout's reps based exercises. For bodyweight exercises
+// the load is the bodyweight itself.
+func (w *Workout) SetBodyweight(bodyweight Weight) {
+	for i := range w.Exercises {
+		w.Exercises[i].bodyweight = bodyweight
+	}
+}
+
+// Copy returns a new unsaved workout owned by ownerID with the same name and
+// exercises as w. Progress of the exercises is not copied.
+func (w Workout) Copy(ownerID int) *Workout {
+	exercises := make([]WorkoutExercise, len(w.Exercises))
+
+	for i, exercise := range w.Exercises {
+		exercises[i] = exercise
+		exercises[i].ID = 0
+		exercises[i].Version = 0
+		exercises[i].Done = false
+		exercises[i].ActualReps = 0
+		exercises[i].ActualWeights = 0
+		exercises[i].ActualDuration = 0
+		exercises[i].ActualDistance = 0
+	}
+
+	blocks := make([]WorkoutBlock, len(w.Blocks))
+
+	for i, block := range w.Blocks {
+		blocks[i] = block
+		blocks[i].ID = 0
+		blocks[i].Version = 0
+	}
+
+	return &Workout{
+		OwnerID:           ownerID,
+		Name:              w.Name,
+		Exercises:         exercises,
+		Blocks:            blocks,
+		NumberOfExercises: len(exercises),
+	}
+}
+
+// Done tells whether every exercise of the workout was performed.
+func (w Workout) Done() bool {
+	for _, exercise := range w.Exercises {
+		if !exercise.Done {
+			return false
+		}
+	}
+
+	return len(w.Exercises) > 0
+}
+
+// secondsPerSet is assumed for sets that aren't timed when estimating the
+// duration of a workout.
+const secondsPerSet = 45
+
+// EstimatedDuration returns roughly how long performing the workout takes
+// including rest between sets, at least 15 minutes.
+func (w Workout) EstimatedDuration() time.Duration {
+	seconds := 0
+
+	for _, exercise := range w.Exercises {
+		perSet := secondsPerSet
+		if exercise.Duration > 0 {
+			perSet = exercise.Duration
+		}
+
+		seconds += exercise.Sets * (perSet + exercise.RestAfter)
+	}
+
+	return max(time.Duration(seconds)*time.Second, 15*time.Minute)
 }
 
 type WorkoutExercise struct {
 	ID       int       `json:"id"`
-	Order    int       `json:"order"` // order in the workout
+	Order    int       `json:"order"`           // order in the workout
+	Block    int       `json:"block,omitempty"` // order of the block, 0 if not grouped
 	Exercise *Exercise `json:"exercise"`
 	Sets     int       `json:"sets"`
-	Reps     int       `json:"reps,omitempty"`
-	Weights  float32   `json:"weights,omitempty"`
+
+	// Targets of each set, only the ones matching the measurement of the
+	// exercise are set.
+	Reps     int      `json:"reps,omitempty"`
+	Weights  Weight   `json:"weights,omitempty"`
+	Duration int      `json:"duration,omitempty"` // in seconds
+	Distance Distance `json:"distance,omitempty"`
+
+	// What was actually performed in each set.
+	ActualReps     int      `json:"actual_reps,omitempty"`
+	ActualWeights  Weight   `json:"actual_weights,omitempty"`
+	ActualDuration int      `json:"actual_duration,omitempty"` // in seconds
+	ActualDistance Distance `json:"actual_distance,omitempty"`
+
+	// WeightUnit overrides the owner's preferred weight unit for this entry,
+	// e.g. when training with pound plates in a kilogram gym.
+	WeightUnit WeightUnit `json:"weight_unit,omitempty"`
 
 	// in seconds
 	RestAfter int  `json:"rest_after,omitempty"`
 	Done      bool `json:"done"`
 	Version   int  `json:"-"`
+
+	// units the entry is presented in, see SetUnits.
+	units Units
+
+	// latest bodyweight of the owner, see SetBodyweight.
+	bodyweight Weight
 }
 
 func (e WorkoutExercise) Validate(v *validator.Validator) {
@@ -54,48 +170,243 @@ func (e WorkoutExercise) Validate(v *validator.Validator) {
 	v.Check(e.Sets > 0, "sets", "must be a positive number")
 	v.Check(e.Sets < 1000, "sets", "must be less than 1000")
 
-	v.Check(e.Reps >= 0, "reps", "must be a positive number")
-	v.Check(e.Reps < 1000, "reps", "must be less than 1000")
+	if e.WeightUnit != "" {
+		_, err := GetWeightUnit(string(e.WeightUnit))
+		v.Check(err == nil, "weight_unit", "invalid weight unit")
+	}
+
+	m := e.Exercise.Measurement
+
+	checkMeasure(v, m, m.hasReps(), true, float64(e.Reps), 1000, "1000", "reps")
+	checkMeasure(v, m, m.hasWeights(), false, float64(e.Weights), 1_000_000, "1000 kg", "weights")
+	checkMeasure(v, m, m.hasDuration(), true, float64(e.Duration), 6*60*60, "6 hours", "duration")
+	checkMeasure(v, m, m.hasDistance(), true, float64(e.Distance), 100_000, "100 km", "distance")
 
-	v.Check(e.Weights >= 0, "weights", "must be a positive number")
-	v.Check(e.Weights < 1000, "weights", "must be less than 1000")
+	checkMeasure(v, m, m.hasReps(), false, float64(e.ActualReps), 1000, "1000", "actual_reps")
+	checkMeasure(v, m, m.hasWeights(), false, float64(e.ActualWeights), 1_000_000, "1000 kg", "actual_weights")
+	checkMeasure(v, m, m.hasDuration(), false, float64(e.ActualDuration), 6*60*60, "6 hours", "actual_duration")
+	checkMeasure(v, m, m.hasDistance(), false, float64(e.ActualDistance), 100_000, "100 km", "actual_distance")
 
 	v.Check(e.RestAfter >= 0, "rest_after", "must be a positive number")
 	v.Check(e.RestAfter < 15*60, "rest_after", "must be less than 1000")
 }
 
+// checkMeasure validates a value of a workout exercise. Values that don't
+// apply to the measurement m must be empty, required values must be set.
+// limit is the human readable form of maximum.
+func checkMeasure(v *validator.Validator, m Measurement, applies, required bool, value, maximum float64, limit, key string) {
+	if !applies {
+		v.Check(value == 0, key, fmt.Sprintf("must be empty for %s exercises", m))
+		return
+	}
+
+	if required {
+		v.Check(value > 0, key, "must be a positive number")
+	} else {
+		v.Check(value >= 0, key, "must be a positive number")
+	}
+	v.Check(value < maximum, key, "must be less than "+limit)
+}
+
+// weightUnit returns the unit the entry's weights are presented in.
+func (e WorkoutExercise) weightUnit() WeightUnit {
+	switch {
+	case e.WeightUnit != "":
+		return e.WeightUnit
+	case e.units.Weight != "":
+		return e.units.Weight
+	default:
+		return DefaultUnits.Weight
+	}
+}
+
+// distanceUnit returns the unit the entry's distances are presented in.
+func (e WorkoutExercise) distanceUnit() DistanceUnit {
+	if e.units.Distance != "" {
+		return e.units.Distance
+	}
+
+	return DefaultUnits.Distance
+}
+
+// DisplayUnits returns the units the entry's weights and distances are
+// presented in.
+func (e WorkoutExercise) DisplayUnits() Units {
+	return Units{Weight: e.weightUnit(), Distance: e.distanceUnit()}
+}
+
+// String describes the targets of the entry in its display units, e.g.
+// "Bench Press: 3 x 8 @ 80 kg".
+func (e WorkoutExercise) String() string {
+	m := e.Exercise.Measurement
+
+	var target []string
+	if m.hasReps() {
+		target = append(target, fmt.Sprintf("%d x %d", e.Sets, e.Reps))
+	} else {
+		target = append(target, fmt.Sprintf("%d x", e.Sets))
+	}
+	if m.hasDuration() {
+		target = append(target, fmt.Sprintf("%ds", e.Duration))
+	}
+	if m.hasDistance() {
+		target = append(target, fmt.Sprintf("%g %s", e.Distance.In(e.distanceUnit()), e.distanceUnit()))
+	}
+	if m.hasWeights() && e.Weights > 0 {
+		target = append(target, fmt.Sprintf("@ %g %s", e.Weights.In(e.weightUnit()), e.weightUnit()))
+	}
+
+	return e.Exercise.Name + ": " + strings.Join(target, " ")
+}
+
+// load returns the weight moved in each rep, which is the bodyweight for
+// bodyweight exercises.
+func (e WorkoutExercise) load() Weight {
+	if e.Exercise.Measurement == MeasurementReps {
+		return e.bodyweight
+	}
+
+	return e.Weights
+}
+
+// MarshalJSON presents weights and distances in the entry's units instead
+// of their canonical form, along with the load and relative strength when
+// the bodyweight is known.
+func (e WorkoutExercise) MarshalJSON() ([]byte, error) {
+	type alias WorkoutExercise
+
+	output := struct {
+		alias
+		Weights        float64      `json:"weights,omitempty"`
+		ActualWeights  float64      `json:"actual_weights,omitempty"`
+		WeightUnit     WeightUnit   `json:"weight_unit,omitempty"`
+		Distance       float64      `json:"distance,omitempty"`
+		ActualDistance float64      `json:"actual_distance,omitempty"`
+		DistanceUnit   DistanceUnit `json:"distance_unit,omitempty"`
+
+		Load             float64 `json:"load,omitempty"`
+		RelativeStrength float64 `json:"relative_strength,omitempty"`
+	}{
+		alias:          alias(e),
+		Weights:        e.Weights.In(e.weightUnit()),
+		ActualWeights:  e.ActualWeights.In(e.weightUnit()),
+		Distance:       e.Distance.In(e.distanceUnit()),
+		ActualDistance: e.ActualDistance.In(e.distanceUnit()),
+	}
+
+	if e.Exercise != nil && e.Exercise.Measurement.hasWeights() {
+		output.WeightUnit = e.weightUnit()
+	}
+	if e.Exercise != nil && e.Exercise.Measurement.hasDistance() {
+		output.DistanceUnit = e.distanceUnit()
+	}
+	if e.Exercise != nil && e.Exercise.Measurement.hasReps() && e.bodyweight > 0 {
+		output.Load = e.load().In(e.weightUnit())
+		output.RelativeStrength = round(float64(e.load())/float64(e.bodyweight), 2)
+	}
+
+	return json.Marshal(output)
+}
+
 type WorkoutRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func (r *WorkoutRepository) Create(workout *Workout) error {
+func (r *WorkoutRepository) Create(ctx context.Context, workout *Workout) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("can't start transaction: %w", err)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := r.insert(ctx, tx, workout); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// insert adds the workout along with its exercises as part of the given
+// transaction.
+func (r *WorkoutRepository) insert(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	// workouts imported from other apps keep their original date.
 	query := `
-	INSERT INTO workouts(owner_id, name)
-	VALUES ($1, $2)
-	RETURNING id, version
+	INSERT INTO workouts(owner_id, name, created_at)
+	VALUES ($1, $2, COALESCE($3, NOW()))
+	RETURNING id, created_at, version
 	`
+	createdAt := sql.NullTime{Time: workout.CreatedAt, Valid: !workout.CreatedAt.IsZero()}
+	ownerID := sql.NullInt64{Int64: int64(workout.OwnerID), Valid: workout.OwnerID != 0}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = tx.QueryRowContext(ctx, query, workout.OwnerID, workout.Name).Scan(
+	err := tx.QueryRowContext(ctx, query, ownerID, workout.Name, createdAt).Scan(
 		&workout.ID,
+		&workout.CreatedAt,
 		&workout.Version,
 	)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("error inserting to workouts: %w", err)
 	}
 
-	query = `
+	if err := r.insertBlocks(ctx, tx, workout); err != nil {
+		return fmt.Errorf("error inserting to workout_blocks: %w", err)
+	}
+
+	if err := r.insertExercises(ctx, tx, workout); err != nil {
+		return fmt.Errorf("error inserting to workouts_exercises: %w", err)
+	}
+
+	return nil
+}
+
+// insertBlocks adds the blocks of the workout as part of the given
+// transaction.
+func (r *WorkoutRepository) insertBlocks(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `
+	INSERT INTO workout_blocks(workout_id, block_order, block_type, rounds,
+	rest_between_rounds, duration)
+	VALUES($1, $2, $3, $4, $5, $6)
+	RETURNING id, version
+	`
+
+	for i, block := range workout.Blocks {
+		args := []any{
+			workout.ID,
+			block.Order,
+			block.Type,
+			block.Rounds,
+			block.RestBetweenRounds,
+			block.Duration,
+		}
+		err := tx.QueryRowContext(ctx, query, args...).Scan(
+			&workout.Blocks[i].ID,
+			&workout.Blocks[i].Version,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertExercises adds the exercises of the workout as part of the given
+// transaction.
+func (r *WorkoutRepository) insertExercises(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `
 	INSERT INTO workouts_exercises(workout_id, exercise_id, exercise_order,
-	sets, reps, weights, rest_after, done)
-	VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	block_order, sets, reps, weights, duration, distance, actual_reps,
+	actual_weights, actual_duration, actual_distance, weight_unit, rest_after,
+	done)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	RETURNING id, version
 	`
 
@@ -104,9 +415,17 @@ func (r *WorkoutRepository) Create(workout *Workout) error {
 			workout.ID,
 			exercise.Exercise.ID,
 			exercise.Order,
+			exercise.Block,
 			exercise.Sets,
 			exercise.Reps,
 			exercise.Weights,
+			exercise.Duration,
+			exercise.Distance,
+			exercise.ActualReps,
+			exercise.ActualWeights,
+			exercise.ActualDuration,
+			exercise.ActualDistance,
+			exercise.WeightUnit,
 			exercise.RestAfter,
 			exercise.Done,
 		}
@@ -115,166 +434,273 @@ func (r *WorkoutRepository) Create(workout *Workout) error {
 			&workout.Exercises[i].Version,
 		)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("error inserting to workouts_exercises: %w", err)
+			return err
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
-		return err
-	}
-
 	return nil
 }
 
-func (r *WorkoutRepository) GetAllByID(ownerID int) ([]*Workout, error) {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-
-	// get basic info of each workout (not including exercises)
+// BestActualWeights returns the heaviest weight the owner actually lifted
+// in each of the given exercises, leaving out the workout excludeID.
+// Exercises never performed are missing from the result.
+func (r *WorkoutRepository) BestActualWeights(ctx context.Context, ownerID, excludeID int, exerciseIDs []int) (map[int]Weight, error) {
 	query := `
-	SELECT id, owner_id, name, version
-	FROM workouts
-	WHERE owner_id = $1
+	SELECT we.exercise_id, MAX(we.actual_weights)
+	FROM workouts_exercises AS we
+	JOIN workouts AS w ON w.id = we.workout_id
+	WHERE w.owner_id = $1 AND w.id <> $2 AND we.done
+	AND we.exercise_id = ANY($3)
+	GROUP BY we.exercise_id
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	rows, err := tx.QueryContext(ctx, query, ownerID)
+	rows, err := r.db.QueryContext(ctx, query, ownerID, excludeID, pq.Array(exerciseIDs))
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrNotFound
-		default:
+		return nil, err
+	}
+	defer rows.Close()
+
+	best := map[int]Weight{}
+
+	for rows.Next() {
+		var exerciseID int
+		var weight Weight
+
+		if err := rows.Scan(&exerciseID, &weight); err != nil {
 			return nil, err
 		}
+
+		best[exerciseID] = weight
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return best, nil
+}
+
+// GetAll returns a page of the owner's workouts matching the given search
+// parameters, each one populated with its exercises. name is matched using
+// postgres text search, exerciseID keeps only the workouts that include the
+// given exercise and muscle keeps only the workouts that target it. Zero
+// values disable the corresponding filter. Workouts backing templates are
+// not included.
+func (r *WorkoutRepository) GetAll(ctx context.Context, ownerID int, name string, exerciseID int, muscle string, filters Filters) ([]*Workout, Metadata, error) {
+	// get basic info of the workouts in the requested page (not including
+	// exercises)
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) OVER(), w.id, w.owner_id, w.name, w.created_at, w.version
+	FROM workouts AS w
+	WHERE w.owner_id = $1
+	AND NOT EXISTS (SELECT 1 FROM workout_templates AS t WHERE t.workout_id = w.id)
+	AND (to_tsvector('simple', w.name) @@ plainto_tsquery('simple', $2) OR $2 = '')
+	AND ($3 = 0 OR EXISTS (
+		SELECT 1 FROM workouts_exercises AS we
+		WHERE we.workout_id = w.id AND we.exercise_id = $3
+	))
+	AND ($4 = '' OR EXISTS (
+		SELECT 1 FROM workouts_exercises AS we
+		JOIN exercises AS e ON e.id = we.exercise_id
+		WHERE we.workout_id = w.id AND e.muscle = $4
+	))
+	ORDER BY w.%s %s, w.id ASC
+	LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	args := []any{ownerID, name, exerciseID, muscle, filters.limit(), filters.offset()}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
+	totalRecords := 0
 	workouts := make([]*Workout, 0)
 
 	for rows.Next() {
-		workout := Workout{OwnerID: ownerID}
+		var workout Workout
+
 		err := rows.Scan(
+			&totalRecords,
 			&workout.ID,
 			&workout.OwnerID,
 			&workout.Name,
+			&workout.CreatedAt,
 			&workout.Version,
 		)
-
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
+
 		workouts = append(workouts, &workout)
 	}
 
-	if rows.Err() != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
 	}
 
-	// get the exercises for each workout
-	query = `
-	SELECT we.id, we.exercise_order, we.sets, we.reps, we.weights,
-	we.rest_after, we.done, we.version, e.id, e.name, e.muscle,
-	e.instructions, e.additional_info, e.image_url, e.version
-	FROM workouts_exercises AS we
-	JOIN exercises AS e ON e.id = we.exercise_id
-	WHERE we.workout_id = $1
+	if err := r.loadExercises(ctx, workouts); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return workouts, metadata, nil
+}
+
+// eachBatchSize is the number of workouts loaded at a time by Each.
+const eachBatchSize = 100
+
+// Each calls fn with every workout of the owner created within [from, to)
+// in order of creation, zero from or to leave that side open. Workouts are
+// loaded in batches so they are never all held in memory. Iteration stops
+// at the first error returned by fn.
+func (r *WorkoutRepository) Each(ctx context.Context, ownerID int, from, to time.Time, fn func(*Workout) error) error {
+	query := `
+	SELECT w.id, w.owner_id, w.name, w.created_at, w.version
+	FROM workouts AS w
+	WHERE w.owner_id = $1
+	AND NOT EXISTS (SELECT 1 FROM workout_templates AS t WHERE t.workout_id = w.id)
+	AND ($2::timestamptz IS NULL OR w.created_at >= $2)
+	AND ($3::timestamptz IS NULL OR w.created_at < $3)
+	AND (w.created_at, w.id) > ($4::timestamptz, $5::int)
+	ORDER BY w.created_at, w.id
+	LIMIT $6
 	`
 
-	for _, workout := range workouts {
-		rows, err := tx.QueryContext(ctx, query, workout.ID)
+	// position after the last workout of the previous batch
+	var lastCreatedAt time.Time
+	lastID := 0
+
+	for {
+		workouts, err := r.eachBatch(ctx, query, ownerID, from, to, lastCreatedAt, lastID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		defer rows.Close()
-
-		var exercises []WorkoutExercise
-
-		for rows.Next() {
-			var workoutExercise WorkoutExercise
-			var exercise Exercise
-
-			err := rows.Scan(
-				&workoutExercise.ID,
-				&workoutExercise.Order,
-				&workoutExercise.Sets,
-				&workoutExercise.Reps,
-				&workoutExercise.Weights,
-				&workoutExercise.RestAfter,
-				&workoutExercise.Done,
-				&workoutExercise.Version,
-				&exercise.ID,
-				&exercise.Name,
-				&exercise.Muscle,
-				&exercise.Instructions,
-				&exercise.AdditionalInfo,
-				&exercise.ImageURL,
-				&exercise.Version,
-			)
-
-			if err != nil {
-				return nil, err
+		for _, workout := range workouts {
+			if err := fn(workout); err != nil {
+				return err
 			}
+		}
 
-			workoutExercise.Exercise = &exercise
-			exercises = append(exercises, workoutExercise)
+		if len(workouts) < eachBatchSize {
+			return nil
 		}
 
-		workout.Exercises = exercises
-		workout.NumberOfExercises = len(workout.Exercises)
+		last := workouts[len(workouts)-1]
+		lastCreatedAt, lastID = last.CreatedAt, last.ID
+	}
+}
+
+func (r *WorkoutRepository) eachBatch(ctx context.Context, query string, ownerID int, from, to, lastCreatedAt time.Time, lastID int) ([]*Workout, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	args := []any{
+		ownerID,
+		sql.NullTime{Time: from, Valid: !from.IsZero()},
+		sql.NullTime{Time: to, Valid: !to.IsZero()},
+		lastCreatedAt,
+		lastID,
+		eachBatchSize,
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := make([]*Workout, 0, eachBatchSize)
+
+	for rows.Next() {
+		var workout Workout
+
+		err := rows.Scan(
+			&workout.ID,
+			&workout.OwnerID,
+			&workout.Name,
+			&workout.CreatedAt,
+			&workout.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		workouts = append(workouts, &workout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadExercises(ctx, workouts); err != nil {
+		return nil, err
 	}
 
 	return workouts, nil
 }
 
-func (r *WorkoutRepository) GetWorkoutByID(ownerID, workoutID int) (*Workout, error) {
+// loadExercises populates the exercises and blocks of all the given workouts
+// using a single query for each.
+func (r *WorkoutRepository) loadExercises(ctx context.Context, workouts []*Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+
+	workoutIDs := make([]int, len(workouts))
+	for i, workout := range workouts {
+		workoutIDs[i] = workout.ID
+	}
+
 	query := `
-	SELECT w.name, w.version, we.id, we.exercise_order, we.sets,
-	we.reps, we.weights, we.rest_after, we.done, we.version, e.id, e.name,
-	e.muscle, e.instructions, e.additional_info, e.image_url, e.version
-	FROM workouts AS w
-	JOIN workouts_exercises AS we ON w.id = we.workout_id
-	JOIN exercises AS e ON we.exercise_id = e.id
-	WHERE w.owner_id = $1 AND w.id = $2
+	SELECT we.workout_id, we.id, we.exercise_order, we.block_order, we.sets,
+	we.reps, we.weights, we.duration, we.distance, we.actual_reps,
+	we.actual_weights, we.actual_duration, we.actual_distance, we.weight_unit,
+	we.rest_after, we.done, we.version, e.id, e.name, e.muscle, e.instructions,
+	e.additional_info, e.image_url, e.measurement, e.version
+	FROM workouts_exercises AS we
+	JOIN exercises AS e ON e.id = we.exercise_id
+	WHERE we.workout_id = ANY($1)
+	ORDER BY we.workout_id, we.exercise_order
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	rows, err := r.db.QueryContext(ctx, query, ownerID, workoutID)
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(workoutIDs))
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrNotFound
-		default:
-			return nil, err
-		}
+		return err
 	}
 	defer rows.Close()
 
-	workout := &Workout{
-		OwnerID: ownerID,
-		ID:      workoutID,
-	}
+	exercises := make(map[int][]WorkoutExercise, len(workouts))
 
 	for rows.Next() {
+		var workoutID int
 		var workoutExercise WorkoutExercise
 		var exercise Exercise
 
 		err := rows.Scan(
-			&workout.Name,
-			&workout.Version,
+			&workoutID,
 			&workoutExercise.ID,
 			&workoutExercise.Order,
+			&workoutExercise.Block,
 			&workoutExercise.Sets,
 			&workoutExercise.Reps,
 			&workoutExercise.Weights,
+			&workoutExercise.Duration,
+			&workoutExercise.Distance,
+			&workoutExercise.ActualReps,
+			&workoutExercise.ActualWeights,
+			&workoutExercise.ActualDuration,
+			&workoutExercise.ActualDistance,
+			&workoutExercise.WeightUnit,
 			&workoutExercise.RestAfter,
 			&workoutExercise.Done,
 			&workoutExercise.Version,
@@ -284,16 +710,121 @@ func (r *WorkoutRepository) GetWorkoutByID(ownerID, workoutID int) (*Workout, er
 			&exercise.Instructions,
 			&exercise.AdditionalInfo,
 			&exercise.ImageURL,
+			&exercise.Measurement,
 			&exercise.Version,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		workoutExercise.Exercise = &exercise
-		workout.Exercises = append(workout.Exercises, workoutExercise)
+		exercises[workoutID] = append(exercises[workoutID], workoutExercise)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, workout := range workouts {
+		workout.Exercises = exercises[workout.ID]
+		workout.NumberOfExercises = len(workout.Exercises)
+	}
+
+	return r.loadBlocks(ctx, workouts)
+}
+
+// loadBlocks populates the blocks of all the given workouts using a single
+// query.
+func (r *WorkoutRepository) loadBlocks(ctx context.Context, workouts []*Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+
+	workoutIDs := make([]int, len(workouts))
+	for i, workout := range workouts {
+		workoutIDs[i] = workout.ID
+	}
+
+	query := `
+	SELECT workout_id, id, block_order, block_type, rounds,
+	rest_between_rounds, duration, version
+	FROM workout_blocks
+	WHERE workout_id = ANY($1)
+	ORDER BY workout_id, block_order
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(workoutIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	blocks := make(map[int][]WorkoutBlock, len(workouts))
+
+	for rows.Next() {
+		var workoutID int
+		var block WorkoutBlock
+
+		err := rows.Scan(
+			&workoutID,
+			&block.ID,
+			&block.Order,
+			&block.Type,
+			&block.Rounds,
+			&block.RestBetweenRounds,
+			&block.Duration,
+			&block.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		blocks[workoutID] = append(blocks[workoutID], block)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, workout := range workouts {
+		workout.Blocks = blocks[workout.ID]
+	}
+
+	return nil
+}
+
+func (r *WorkoutRepository) GetWorkoutByID(ctx context.Context, ownerID, workoutID int) (*Workout, error) {
+	query := `
+	SELECT name, created_at, version
+	FROM workouts
+	WHERE owner_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	workout := &Workout{
+		OwnerID: ownerID,
+		ID:      workoutID,
+	}
+
+	err := r.db.QueryRowContext(ctx, query, ownerID, workoutID).Scan(
+		&workout.Name,
+		&workout.CreatedAt,
+		&workout.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := r.loadExercises(ctx, []*Workout{workout}); err != nil {
+		return nil, err
 	}
-	workout.NumberOfExercises = len(workout.Exercises)
 
 	if workout.NumberOfExercises == 0 {
 		return nil, ErrNotFound
@@ -302,12 +833,31 @@ func (r *WorkoutRepository) GetWorkoutByID(ownerID, workoutID int) (*Workout, er
 	return workout, nil
 }
 
-func (r *WorkoutRepository) Update(workout *Workout) error {
+func (r *WorkoutRepository) Update(ctx context.Context, workout *Workout) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := r.update(ctx, tx, workout); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// update replaces the workout and its exercises as part of the given
+// transaction.
+func (r *WorkoutRepository) update(ctx context.Context, tx *sql.Tx, workout *Workout) error {
 	query := `
 	UPDATE workouts SET name = $1, version = version + 1
 	WHERE id = $2 AND version = $3
@@ -315,10 +865,7 @@ func (r *WorkoutRepository) Update(workout *Workout) error {
 	`
 	args := []any{workout.Name, workout.ID, workout.Version}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = tx.QueryRowContext(ctx, query, args...).Scan(&workout.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&workout.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -334,40 +881,23 @@ func (r *WorkoutRepository) Update(workout *Workout) error {
 		return err
 	}
 
-	query = `
-	INSERT INTO workouts_exercises(workout_id, exercise_id, exercise_order, sets, reps, weights, rest_after, done)
-	VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-	RETURNING id, version
-	`
+	query = `DELETE from workout_blocks WHERE workout_id = $1`
+	_, err = tx.ExecContext(ctx, query, workout.ID)
+	if err != nil {
+		return err
+	}
 
-	for i, exercise := range workout.Exercises {
-		args := []any{
-			workout.ID,
-			exercise.Exercise.ID,
-			exercise.Order,
-			exercise.Sets,
-			exercise.Reps,
-			exercise.Weights,
-			exercise.RestAfter,
-			exercise.Done,
-		}
-		err := tx.QueryRowContext(ctx, query, args...).Scan(
-			&workout.Exercises[i].ID,
-			&workout.Exercises[i].Version,
-		)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+	if err := r.insertBlocks(ctx, tx, workout); err != nil {
+		return err
 	}
 
-	return nil
+	return r.insertExercises(ctx, tx, workout)
 }
 
-func (r *WorkoutRepository) Delete(ownerID, workoutID int) error {
+func (r *WorkoutRepository) Delete(ctx context.Context, ownerID, workoutID int) error {
 	query := `DELETE FROM workouts WHERE id = $1 AND owner_id = $2`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	result, err := r.db.ExecContext(ctx, query, workoutID, ownerID)
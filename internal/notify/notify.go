@@ -0,0 +1,22 @@
+// Package notify delivers notifications to users over pluggable channels.
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Message is the content of a notification.
+type Message struct {
+	Event   string         `json:"event"`
+	Subject string         `json:"subject"`
+	Body    string         `json:"body"`
+	Data    map[string]any `json:"data,omitempty"`
+	SentAt  time.Time      `json:"sent_at"`
+}
+
+// Channel sends messages to an address, an email address or a webhook url
+// depending on the channel.
+type Channel interface {
+	Send(ctx context.Context, address string, msg Message) error
+}
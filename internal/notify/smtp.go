@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTP sends messages as plain text emails. Any SMTP server works,
+// including local stand-ins such as MailHog or Mailpit during development.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+func (s *SMTP) Send(ctx context.Context, address string, msg Message) error {
+	// the sender may include a display name, e.g. "Jasad <no-reply@jasad.app>"
+	from, err := mail.ParseAddress(s.Sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+
+	var body bytes.Buffer
+
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", address)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", msg.SentAt.Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&body, "\r\n%s\r\n", msg.Body)
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	// net/smtp doesn't take a context, the send is abandoned instead when
+	// the context is done.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, from.Address, []string{address}, body.Bytes())
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook url resolves to an address
+// of the host itself, of a private network or of a reserved range.
+var ErrForbiddenAddress = errors.New("forbidden address")
+
+// WebhookError is a failed request to a webhook. Its message is shown to
+// the owner of the webhook, the underlying error isn't as it may reveal
+// the network the server runs in.
+type WebhookError struct {
+	Message string
+	Err     error
+}
+
+func (e *WebhookError) Error() string {
+	return e.Message
+}
+
+func (e *WebhookError) Unwrap() error {
+	return e.Err
+}
+
+// Webhook posts messages as JSON to user configured urls, any status other
+// than 2xx is an error.
+type Webhook struct {
+	Client *http.Client
+}
+
+// NewWebhook returns a Webhook that only connects to public addresses, the
+// urls are set by users who shouldn't reach the services next to the
+// server. Redirects aren't followed, a redirected request fails with its
+// 3xx status.
+func NewWebhook() *Webhook {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkAddress}
+
+	return &Webhook{Client: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// reservedPrefixes are global unicast ranges that aren't reachable on the
+// internet, or reach networks of the operator.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// nat64Prefix holds IPv6 addresses translated to the IPv4 address in their
+// last 4 bytes.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// checkAddress refuses connections to addresses that aren't public, it's
+// called with the resolved address so names pointing to them are refused
+// too.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := addrPort.Addr().Unmap()
+
+	// the IPv4 address is the one reached through the translator
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		ip = netip.AddrFrom4([4]byte(b[12:]))
+	}
+
+	// loopback, link local, multicast and unspecified addresses aren't
+	// global unicast ones.
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return ErrForbiddenAddress
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+func (wh *Webhook) Send(ctx context.Context, address string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = wh.post(ctx, address, body, nil)
+
+	return err
+}
+
+// Delivery is an event posted to a subscribed webhook.
+type Delivery struct {
+	ID      int64
+	Event   string
+	Payload []byte
+}
+
+// Deliver posts the payload of the delivery to url signed with secret. It
+// returns the status code of the response, 0 if there was none.
+//
+// The signature is sent in the X-Jasad-Signature header as
+// "t=<unix time>,v1=<hex hmac>", where the hmac is the HMAC-SHA256 of
+// "<unix time>.<body>" keyed with the secret, so receivers can verify the
+// payload and reject replayed ones.
+func (wh *Webhook) Deliver(ctx context.Context, url, secret string, d Delivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	header := http.Header{}
+	header.Set("X-Jasad-Event", d.Event)
+	header.Set("X-Jasad-Delivery", strconv.FormatInt(d.ID, 10))
+	header.Set("X-Jasad-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(secret, timestamp, d.Payload)))
+
+	return wh.post(ctx, url, d.Payload, header)
+}
+
+// Sign returns the hex encoded signature of the body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wh *Webhook) post(ctx context.Context, address string, body []byte, header http.Header) (int, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, &WebhookError{Message: "webhook url must be an http or https url", Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return 0, &WebhookError{Message: "webhook url is invalid", Err: err}
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Jasad-Webhook/1.0")
+
+	res, err := wh.Client.Do(req)
+	if err != nil {
+		return 0, &WebhookError{Message: requestFailure(err), Err: err}
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// requestFailure describes why a request to a webhook failed without the
+// details of the network.
+func requestFailure(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, ErrForbiddenAddress):
+		return "webhook url resolves to a forbidden address"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "webhook timed out"
+	default:
+		return "webhook could not be reached"
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS workouts_exercises_workout_id_idx;
+DROP INDEX IF EXISTS workouts_name_idx;
+DROP INDEX IF EXISTS workouts_owner_id_idx;
+
+ALTER TABLE workouts DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,5 @@
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS workouts_owner_id_idx ON workouts(owner_id);
+CREATE INDEX IF NOT EXISTS workouts_name_idx ON workouts USING GIN (to_tsvector('simple', name));
+CREATE INDEX IF NOT EXISTS workouts_exercises_workout_id_idx ON workouts_exercises(workout_id);
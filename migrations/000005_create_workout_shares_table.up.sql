@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS workout_shares(
+	id SERIAL PRIMARY KEY,
+	hash BYTEA NOT NULL UNIQUE,
+	workout_id INT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	expires_at TIMESTAMP(0) WITH TIME ZONE,
+	revoked BOOL NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS workout_shares_workout_id_idx ON workout_shares(workout_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS workout_templates(
+	id SERIAL PRIMARY KEY,
+	workout_id INT NOT NULL UNIQUE REFERENCES workouts(id) ON DELETE CASCADE,
+	description TEXT NOT NULL,
+	goal VARCHAR(30) NOT NULL,
+	level VARCHAR(30) NOT NULL,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	version INT NOT NULL DEFAULT 1
+);
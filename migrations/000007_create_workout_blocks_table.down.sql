@@ -0,0 +1,3 @@
+ALTER TABLE workouts_exercises DROP COLUMN IF EXISTS block_order;
+
+DROP TABLE IF EXISTS workout_blocks;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS workout_blocks(
+	id SERIAL PRIMARY KEY,
+	workout_id INT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	block_order INT NOT NULL,
+	block_type VARCHAR(30) NOT NULL,
+	rounds INT NOT NULL,
+	rest_between_rounds INT NOT NULL,
+	duration INT NOT NULL,
+	version INT NOT NULL DEFAULT 1,
+	UNIQUE(workout_id, block_order)
+);
+
+ALTER TABLE workouts_exercises ADD COLUMN IF NOT EXISTS block_order INT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+ALTER TABLE workouts_exercises
+	DROP COLUMN IF EXISTS duration,
+	DROP COLUMN IF EXISTS distance,
+	DROP COLUMN IF EXISTS actual_reps,
+	DROP COLUMN IF EXISTS actual_weights,
+	DROP COLUMN IF EXISTS actual_duration,
+	DROP COLUMN IF EXISTS actual_distance;
+
+ALTER TABLE exercises DROP COLUMN IF EXISTS measurement;
@@ -0,0 +1,9 @@
+ALTER TABLE exercises ADD COLUMN IF NOT EXISTS measurement VARCHAR(30) NOT NULL DEFAULT 'reps and weight';
+
+ALTER TABLE workouts_exercises
+	ADD COLUMN IF NOT EXISTS duration INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS distance REAL NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS actual_reps INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS actual_weights REAL NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS actual_duration INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS actual_distance REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+ALTER TABLE workouts_exercises
+	DROP COLUMN IF EXISTS weight_unit,
+	ALTER COLUMN weights TYPE REAL USING weights / 1000.0,
+	ALTER COLUMN actual_weights TYPE REAL USING actual_weights / 1000.0,
+	ALTER COLUMN distance TYPE REAL USING distance,
+	ALTER COLUMN actual_distance TYPE REAL USING actual_distance;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS weight_unit,
+	DROP COLUMN IF EXISTS distance_unit;
@@ -0,0 +1,11 @@
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(10) NOT NULL DEFAULT 'kg',
+	ADD COLUMN IF NOT EXISTS distance_unit VARCHAR(10) NOT NULL DEFAULT 'km';
+
+-- weights are stored in grams and distances in meters
+ALTER TABLE workouts_exercises
+	ALTER COLUMN weights TYPE BIGINT USING ROUND(weights * 1000),
+	ALTER COLUMN actual_weights TYPE BIGINT USING ROUND(actual_weights * 1000),
+	ALTER COLUMN distance TYPE BIGINT USING ROUND(distance),
+	ALTER COLUMN actual_distance TYPE BIGINT USING ROUND(actual_distance),
+	ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+ALTER TABLE users
+	DROP COLUMN IF EXISTS display_name,
+	DROP COLUMN IF EXISTS avatar_url,
+	DROP COLUMN IF EXISTS birth_year,
+	DROP COLUMN IF EXISTS sex,
+	DROP COLUMN IF EXISTS height,
+	DROP COLUMN IF EXISTS goal,
+	DROP COLUMN IF EXISTS level,
+	DROP COLUMN IF EXISTS time_zone,
+	DROP COLUMN IF EXISTS default_rest;
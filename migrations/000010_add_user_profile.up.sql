@@ -0,0 +1,10 @@
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS birth_year INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS sex VARCHAR(10) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS height INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS goal VARCHAR(30) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS level VARCHAR(30) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+	ADD COLUMN IF NOT EXISTS default_rest INT NOT NULL DEFAULT 0;
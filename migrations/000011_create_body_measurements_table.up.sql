@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS body_measurements(
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	measured_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+	weight BIGINT NOT NULL DEFAULT 0,
+	body_fat NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	neck NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	chest NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	waist NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	hips NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	arm NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	thigh NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	calf NUMERIC(4, 1) NOT NULL DEFAULT 0,
+	photos JSONB NOT NULL DEFAULT '[]',
+	notes TEXT NOT NULL DEFAULT '',
+	version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS body_measurements_user_id_measured_at_idx ON body_measurements(user_id, measured_at);
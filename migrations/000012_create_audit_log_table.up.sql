@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+	id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES users(id) ON DELETE SET NULL,
+	action VARCHAR(50) NOT NULL,
+	details JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log(user_id);
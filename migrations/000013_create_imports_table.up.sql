@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS imports(
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	source VARCHAR(20) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	total INT NOT NULL DEFAULT 0,
+	imported INT NOT NULL DEFAULT 0,
+	skipped INT NOT NULL DEFAULT 0,
+	errors JSONB NOT NULL DEFAULT '[]',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	finished_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS imports_user_id_idx ON imports(user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS schedule_occurrences;
+DROP TABLE IF EXISTS schedules;
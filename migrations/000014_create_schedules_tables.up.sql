@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS schedules(
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	workout_id INT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	start_date DATE NOT NULL,
+	time_of_day VARCHAR(5) NOT NULL DEFAULT '',
+	rrule TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS schedules_user_id_idx ON schedules(user_id);
+
+CREATE TABLE IF NOT EXISTS schedule_occurrences(
+	schedule_id INT NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+	occurs_on DATE NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (schedule_id, occurs_on)
+);
+
+CREATE TABLE IF NOT EXISTS calendar_feeds(
+	hash BYTEA PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS calendar_feeds_user_id_idx ON calendar_feeds(user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS notification_jobs;
+DROP TABLE IF EXISTS notification_preferences;
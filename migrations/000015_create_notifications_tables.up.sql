@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS notification_preferences(
+	user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	email_reminders BOOL NOT NULL DEFAULT FALSE,
+	webhook_reminders BOOL NOT NULL DEFAULT FALSE,
+	webhook_url TEXT NOT NULL DEFAULT '',
+	remind_before INT NOT NULL DEFAULT 60,
+	version INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS notification_jobs(
+	id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	channel VARCHAR(20) NOT NULL,
+	address TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	data JSONB NOT NULL DEFAULT '{}',
+	dedupe_key TEXT UNIQUE,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	run_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	locked_until TIMESTAMP(0) WITH TIME ZONE,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL DEFAULT 5,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	sent_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS notification_jobs_due_idx ON notification_jobs(status, run_at);
+CREATE INDEX IF NOT EXISTS notification_jobs_user_id_idx ON notification_jobs(user_id);
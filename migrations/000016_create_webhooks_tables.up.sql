@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS webhooks(
+	id SERIAL PRIMARY KEY,
+	-- webhooks without a user are global ones managed by admins
+	user_id INT REFERENCES users(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	active BOOL NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks(user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event VARCHAR(50) NOT NULL,
+	payload JSONB NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	run_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	locked_until TIMESTAMP(0) WITH TIME ZONE,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL DEFAULT 8,
+	response_status INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	delivered_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries(status, run_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries(webhook_id);
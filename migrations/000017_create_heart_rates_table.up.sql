@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS heart_rates(
+	id BIGSERIAL PRIMARY KEY,
+	workout_id INT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	bpm INT NOT NULL,
+	recorded_at TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS heart_rates_workout_id_idx ON heart_rates(workout_id, recorded_at);
@@ -0,0 +1,3 @@
+-- the values cleared by the backfill can't be restored, there's nothing to
+-- undo.
+SELECT 1;
@@ -0,0 +1,14 @@
+-- entries recorded before exercises had measurements may have no reps, and
+-- entries of exercises whose measurement changed may have values that don't
+-- apply to it. Required values are set to at least 1 and the others cleared.
+UPDATE workouts_exercises AS we SET
+	reps = CASE WHEN e.measurement IN ('reps', 'reps and weight') THEN GREATEST(we.reps, 1) ELSE 0 END,
+	actual_reps = CASE WHEN e.measurement IN ('reps', 'reps and weight') THEN GREATEST(we.actual_reps, 0) ELSE 0 END,
+	weights = CASE WHEN e.measurement IN ('reps and weight', 'distance and weight') THEN GREATEST(we.weights, 0) ELSE 0 END,
+	actual_weights = CASE WHEN e.measurement IN ('reps and weight', 'distance and weight') THEN GREATEST(we.actual_weights, 0) ELSE 0 END,
+	duration = CASE WHEN e.measurement = 'duration' THEN GREATEST(we.duration, 1) ELSE 0 END,
+	actual_duration = CASE WHEN e.measurement = 'duration' THEN GREATEST(we.actual_duration, 0) ELSE 0 END,
+	distance = CASE WHEN e.measurement IN ('distance', 'distance and weight') THEN GREATEST(we.distance, 1) ELSE 0 END,
+	actual_distance = CASE WHEN e.measurement IN ('distance', 'distance and weight') THEN GREATEST(we.actual_distance, 0) ELSE 0 END
+FROM exercises AS e
+WHERE we.exercise_id = e.id;
@@ -0,0 +1,8 @@
+-- template workouts are given to the first admin, they're removed when
+-- there's none left.
+UPDATE workouts SET owner_id = (SELECT id FROM users WHERE role = 'admin' ORDER BY id LIMIT 1)
+WHERE owner_id IS NULL;
+
+DELETE FROM workouts WHERE owner_id IS NULL;
+
+ALTER TABLE workouts ALTER COLUMN owner_id SET NOT NULL;
@@ -0,0 +1,9 @@
+-- template workouts belong to the catalog instead of the admin who created
+-- them, so they outlive the admin's account and stay out of the admin's own
+-- workouts. Shares and schedules the admin made of them go along with that.
+ALTER TABLE workouts ALTER COLUMN owner_id DROP NOT NULL;
+
+DELETE FROM workout_shares WHERE workout_id IN (SELECT workout_id FROM workout_templates);
+DELETE FROM schedules WHERE workout_id IN (SELECT workout_id FROM workout_templates);
+
+UPDATE workouts SET owner_id = NULL WHERE id IN (SELECT workout_id FROM workout_templates);
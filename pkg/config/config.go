@@ -1,32 +1,228 @@
+// Package config loads the configuration shared by the binaries from the
+// command line flags, the environment and an optional env file, in that
+// order of precedence.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
-	"github.com/rs/zerolog/log"
 )
 
 type Config struct {
-	DSN                string  `env:"JASAD_DB_DSN"`
-	Origin             string  `env:"ORIGIN"`
-	Port               int     `env:"PORT"`
-	GoogleClientID     string  `env:"GOOGLE_CLIENT_ID"`
-	GoogleClientSecret string  `env:"GOOGLE_CLIENT_SECRET"`
-	LimiterEnable      bool    `env:"LIMITER_ENABLED" envdefault:"true"`
-	LimiterRPS         float64 `env:"LIMITER_RPS" envdefault:"2"`
-	LimiterBurst       int     `env:"LIMITER_BURST" envdefault:"4"`
+	DSN    string `env:"JASAD_DB_DSN"`
+	Origin string `env:"ORIGIN" envDefault:"http://localhost:8080"`
+	Port   int    `env:"PORT" envDefault:"8080"`
+
+	// sign in with Google is disabled when no client is set
+	GoogleClientID     string `env:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `env:"GOOGLE_CLIENT_SECRET"`
+
+	// connection pool of Postgres, 0 open connections means no limit
+	DBMaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" envDefault:"25"`
+	DBMaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" envDefault:"25"`
+	DBConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" envDefault:"15m"`
+
+	// time a query or Redis command is given before it's canceled
+	QueryTimeout time.Duration `env:"QUERY_TIMEOUT" envDefault:"5s"`
+
+	RedisAddr     string `env:"REDIS_ADDR" envDefault:"localhost:6379"`
+	RedisPassword string `env:"REDIS_PASSWORD"`
+	RedisDB       int    `env:"REDIS_DB" envDefault:"0"`
+
+	ServerReadTimeout  time.Duration `env:"SERVER_READ_TIMEOUT" envDefault:"10s"`
+	ServerWriteTimeout time.Duration `env:"SERVER_WRITE_TIMEOUT" envDefault:"30s"`
+	ServerIdleTimeout  time.Duration `env:"SERVER_IDLE_TIMEOUT" envDefault:"1m"`
+
+	// on shutdown the server keeps serving requests for the grace period
+	// after reporting it isn't ready, then waits for the requests in
+	// flight up to the shutdown timeout
+	ShutdownGracePeriod time.Duration `env:"SHUTDOWN_GRACE_PERIOD" envDefault:"5s"`
+	ShutdownTimeout     time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"20s"`
+
+	// the session cookie, Secure can only be turned off for local
+	// development over plain HTTP
+	SessionTTL     time.Duration `env:"SESSION_TTL" envDefault:"72h"`
+	CookieDomain   string        `env:"COOKIE_DOMAIN"`
+	CookieSecure   bool          `env:"COOKIE_SECURE" envDefault:"true"`
+	CookieSameSite string        `env:"COOKIE_SAME_SITE" envDefault:"lax"`
+
+	LimiterEnable bool    `env:"LIMITER_ENABLED" envDefault:"true"`
+	LimiterRPS    float64 `env:"LIMITER_RPS" envDefault:"2"`
+	LimiterBurst  int     `env:"LIMITER_BURST" envDefault:"4"`
+
+	// routes with their own limits, signing in and searching are limited
+	// apart from the rest of the API
+	LimiterRoutes LimiterRoutes `env:"LIMITER_ROUTES" envDefault:"GET /google_login=0.2:5;GET /google_callback=0.2:5;GET /v1/exercises=5:20"`
+
+	// addresses or CIDR ranges of the proxies in front of the API, the
+	// client address is read from X-Forwarded-For when they connect
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+
+	// traces are exported to the OpenTelemetry collector set with the
+	// standard OTEL_EXPORTER_OTLP_ENDPOINT variable
+	TracingEnable      bool    `env:"TRACING_ENABLED" envDefault:"false"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// bearer token Prometheus has to scrape /metrics with, the metrics are
+	// not served when it's empty
+	MetricsToken string `env:"METRICS_TOKEN"`
+
+	// apply pending migrations on start instead of refusing to start
+	MigrateOnStart bool `env:"MIGRATE_ON_START" envDefault:"false"`
+
+	// email reminders are disabled when no SMTP host is set
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     int    `env:"SMTP_PORT" envDefault:"1025"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+	SMTPSender   string `env:"SMTP_SENDER" envDefault:"Jasad <no-reply@jasad.local>"`
+
+	// broker the cmd/mqtt ingester subscribes to device events on. The host
+	// name is appended to the client id so every instance has its own
+	// session, and instances split the events through a shared subscription
+	// of the share group, empty for brokers without shared subscriptions.
+	MQTTBroker     string `env:"MQTT_BROKER" envDefault:"tcp://localhost:1883"`
+	MQTTClientID   string `env:"MQTT_CLIENT_ID" envDefault:"jasad-ingest"`
+	MQTTShareGroup string `env:"MQTT_SHARE_GROUP" envDefault:"jasad-ingest"`
+	MQTTUsername   string `env:"MQTT_USERNAME"`
+	MQTTPassword   string `env:"MQTT_PASSWORD"`
+
+	// port cmd/mqtt serves /metrics on, the metrics are only served when
+	// METRICS_TOKEN is set like the ones of the HTTP server
+	MQTTMetricsPort int `env:"MQTT_METRICS_PORT" envDefault:"9100"`
 }
 
-func Load(fileNames ...string) (*Config, error) {
-	if err := godotenv.Load(fileNames...); err != nil {
-		log.Fatal().Err(err).Msg("Failed to load environment")
+// Load reads the configuration and validates it. Flags are named after
+// the environment variables, e.g. -redis-addr for REDIS_ADDR, and args
+// are parsed as flags when given. The env file is set with -config or
+// CONFIG_FILE, a missing .env is ignored when none is.
+func Load(name string, args []string) (*Config, error) {
+	cfg := &Config{}
+
+	params, err := env.GetFieldParams(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	cfg := &Config{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "env `file` to read the configuration from")
+
+	// keys of the environment variables by flag
+	keys := map[string]string{}
+	for _, p := range params {
+		keys[flagName(p.Key)] = p.Key
+		flags.String(flagName(p.Key), p.DefaultValue, "sets "+p.Key)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	environment, err := readFile(*file)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := env.Parse(cfg); err != nil {
+	maps.Copy(environment, env.ToMap(os.Environ()))
+
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			environment[key] = f.Value.String()
+		}
+	})
+
+	if err := env.ParseWithOptions(cfg, env.Options{Environment: environment}); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
+
+// readFile reads the env file, .env when none is set which may not exist.
+func readFile(path string) (map[string]string, error) {
+	if path != "" {
+		environment, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading the config file: %w", err)
+		}
+
+		return environment, nil
+	}
+
+	environment, err := godotenv.Read(".env")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return map[string]string{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	return environment, nil
+}
+
+// flagName returns the flag of an environment variable.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// LimiterPolicy lets RPS requests per second through, after bursts of up
+// to Burst requests.
+type LimiterPolicy struct {
+	RPS   float64
+	Burst int
+}
+
+// LimiterRoutes are the policies of routes by pattern, set as
+// "<pattern>=<rps>:<burst>" pairs separated by semicolons.
+type LimiterRoutes map[string]LimiterPolicy
+
+func (l *LimiterRoutes) UnmarshalText(text []byte) error {
+	routes := LimiterRoutes{}
+
+	for _, pair := range strings.Split(string(text), ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		pattern, policy, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("limiter route %q must be in the form of <pattern>=<rps>:<burst>", pair)
+		}
+
+		rps, burst, ok := strings.Cut(policy, ":")
+		if !ok {
+			return fmt.Errorf("limiter route %q must be in the form of <pattern>=<rps>:<burst>", pair)
+		}
+
+		var p LimiterPolicy
+		var err error
+
+		if p.RPS, err = strconv.ParseFloat(rps, 64); err != nil || p.RPS <= 0 {
+			return fmt.Errorf("limiter route %q must have a positive rps", pair)
+		}
+
+		if p.Burst, err = strconv.Atoi(burst); err != nil || p.Burst < 1 {
+			return fmt.Errorf("limiter route %q must have a positive burst", pair)
+		}
+
+		routes[strings.TrimSpace(pattern)] = p
+	}
+
+	*l = routes
+
+	return nil
+}
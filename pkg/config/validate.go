@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// Validate returns every invalid setting at once, named after its
+// environment variable.
+func (c *Config) Validate() error {
+	errs := []error{}
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DSN != "", "JASAD_DB_DSN must be set")
+	check(c.Port > 0 && c.Port <= 65535, "PORT must be between 1 and 65535")
+
+	origin, err := url.Parse(c.Origin)
+	check(err == nil && (origin.Scheme == "http" || origin.Scheme == "https") && origin.Host != "",
+		"ORIGIN must be an http or https URL, e.g. https://jasad.example.com")
+
+	check((c.GoogleClientID == "") == (c.GoogleClientSecret == ""),
+		"GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET must be set together")
+
+	check(c.DBMaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.DBMaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.DBMaxOpenConns == 0 || c.DBMaxIdleConns <= c.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS must not be more than DB_MAX_OPEN_CONNS")
+	check(c.DBConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+
+	check(c.RedisAddr != "", "REDIS_ADDR must be set")
+	check(c.RedisDB >= 0, "REDIS_DB must not be negative")
+
+	check(c.QueryTimeout > 0, "QUERY_TIMEOUT must be positive")
+	check(c.ServerReadTimeout > 0, "SERVER_READ_TIMEOUT must be positive")
+	check(c.ServerWriteTimeout > 0, "SERVER_WRITE_TIMEOUT must be positive")
+	check(c.ServerIdleTimeout > 0, "SERVER_IDLE_TIMEOUT must be positive")
+	check(c.ShutdownGracePeriod >= 0, "SHUTDOWN_GRACE_PERIOD must not be negative")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+
+	check(c.SessionTTL > 0, "SESSION_TTL must be positive")
+	check(c.CookieSameSite == "lax" || c.CookieSameSite == "strict" || c.CookieSameSite == "none",
+		"COOKIE_SAME_SITE must be lax, strict or none")
+	check(c.CookieSameSite != "none" || c.CookieSecure,
+		"COOKIE_SECURE must be set when COOKIE_SAME_SITE is none, browsers reject the cookie otherwise")
+
+	if c.LimiterEnable {
+		check(c.LimiterRPS > 0, "LIMITER_RPS must be positive")
+		check(c.LimiterBurst > 0, "LIMITER_BURST must be positive")
+	}
+
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+
+	if c.SMTPHost != "" {
+		check(c.SMTPPort > 0 && c.SMTPPort <= 65535, "SMTP_PORT must be between 1 and 65535")
+
+		_, err = mail.ParseAddress(c.SMTPSender)
+		check(err == nil, "SMTP_SENDER must be an email address when SMTP_HOST is set, e.g. Jasad <no-reply@jasad.example.com>")
+	}
+
+	check(c.MQTTClientID != "", "MQTT_CLIENT_ID must be set")
+	check(!strings.ContainsAny(c.MQTTShareGroup, "/+#"), "MQTT_SHARE_GROUP must not contain /, + or #")
+	check(c.MQTTMetricsPort > 0 && c.MQTTMetricsPort <= 65535, "MQTT_METRICS_PORT must be between 1 and 65535")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package ical writes iCalendar (RFC 5545) documents.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	timeLayout = "20060102T150405Z"
+	dateLayout = "20060102"
+
+	// lines longer than this are folded
+	maxLineLength = 75
+)
+
+type Event struct {
+	UID   string
+	Start time.Time
+	End   time.Time
+
+	// AllDay events only use the dates of Start and End, End being the day
+	// after the last day of the event.
+	AllDay bool
+
+	Summary     string
+	Description string
+
+	// Status is one of TENTATIVE, CONFIRMED or CANCELLED, omitted if empty.
+	Status string
+
+	// RRule is the recurrence rule of the event without the "RRULE:"
+	// prefix, e.g. "FREQ=WEEKLY;BYDAY=MO,WE,FR".
+	RRule string
+}
+
+// Writer streams a calendar, events are written as soon as they are added.
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewWriter starts a calendar named name on w.
+func NewWriter(w io.Writer, name string) *Writer {
+	cw := &Writer{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//Jasad//Jasad//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("X-WR-CALNAME:" + escape(name))
+
+	return cw
+}
+
+func (cw *Writer) WriteEvent(e Event) error {
+	cw.line("BEGIN:VEVENT")
+	cw.line("UID:" + escape(e.UID))
+	cw.line("DTSTAMP:" + time.Now().UTC().Format(timeLayout))
+
+	if e.AllDay {
+		cw.line("DTSTART;VALUE=DATE:" + e.Start.Format(dateLayout))
+		cw.line("DTEND;VALUE=DATE:" + e.End.Format(dateLayout))
+	} else {
+		cw.line("DTSTART:" + e.Start.UTC().Format(timeLayout))
+		cw.line("DTEND:" + e.End.UTC().Format(timeLayout))
+	}
+
+	if e.RRule != "" {
+		cw.line("RRULE:" + e.RRule)
+	}
+
+	cw.line("SUMMARY:" + escape(e.Summary))
+
+	if e.Description != "" {
+		cw.line("DESCRIPTION:" + escape(e.Description))
+	}
+	if e.Status != "" {
+		cw.line("STATUS:" + e.Status)
+	}
+
+	cw.line("END:VEVENT")
+
+	return cw.Flush()
+}
+
+// Close ends the calendar.
+func (cw *Writer) Close() error {
+	cw.line("END:VCALENDAR")
+
+	return cw.Flush()
+}
+
+// Flush writes the buffered lines to the underlying writer.
+func (cw *Writer) Flush() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	cw.err = cw.w.Flush()
+
+	return cw.err
+}
+
+// line writes a content line folding it every 75 octets without splitting
+// UTF-8 characters.
+func (cw *Writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	first := true
+
+	for len(s) > 0 {
+		limit := maxLineLength
+		if !first {
+			limit-- // continuation lines start with a space
+		}
+
+		n := len(s)
+		if n > limit {
+			n = limit
+			for n > 0 && !isCharStart(s[n]) {
+				n--
+			}
+		}
+
+		if !first {
+			cw.w.WriteByte(' ')
+		}
+
+		if _, err := fmt.Fprintf(cw.w, "%s\r\n", s[:n]); err != nil {
+			cw.err = err
+			return
+		}
+
+		s = s[n:]
+		first = false
+	}
+}
+
+// isCharStart reports whether b starts a UTF-8 encoded character.
+func isCharStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,261 @@
+// Package rrule implements the subset of iCalendar recurrence rules
+// (RFC 5545) used to plan workouts: daily, weekly and monthly frequencies
+// with INTERVAL, BYDAY, COUNT and UNTIL.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly            = "WEEKLY"
+	Monthly           = "MONTHLY"
+)
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+
+	// ByDay are the week days of weekly rules, the week day of the start
+	// date if empty.
+	ByDay []time.Weekday
+
+	// Count limits the number of occurrences, 0 for no limit.
+	Count int
+
+	// Until is the last date occurrences may happen on, zero for no limit.
+	Until time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// dayNames are the names week days are written with in the shorthand of
+// weekly rules: full, three letter and two letter names in lower case.
+var dayNames = func() map[string]time.Weekday {
+	names := map[string]time.Weekday{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		names[name] = weekday
+		names[name[:3]] = weekday
+		names[name[:2]] = weekday
+	}
+	return names
+}()
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE,FR". As a shorthand
+// for weekly rules it also accepts week days separated by slashes or commas
+// such as "Mon/Wed/Fri" or "monday,thursday".
+func Parse(s string) (Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+
+	if !strings.Contains(s, "=") {
+		return parseDays(s)
+	}
+
+	rule := Rule{Interval: 1}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch Frequency(strings.ToUpper(value)) {
+			case Daily:
+				rule.Freq = Daily
+			case Weekly:
+				rule.Freq = Weekly
+			case Monthly:
+				rule.Freq = Monthly
+			default:
+				return Rule{}, fmt.Errorf("unsupported frequency %q", value)
+			}
+
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 365 {
+				return Rule{}, errors.New("interval must be between 1 and 365")
+			}
+			rule.Interval = n
+
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return Rule{}, errors.New("count must be between 1 and 1000")
+			}
+			rule.Count = n
+
+		case "UNTIL":
+			if len(value) > 8 {
+				value = value[:8] // date part of a date-time
+			}
+			until, err := time.Parse("20060102", value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid until date %q", value)
+			}
+			rule.Until = until
+
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return Rule{}, fmt.Errorf("invalid week day %q", day)
+				}
+				if !slices.Contains(rule.ByDay, weekday) {
+					rule.ByDay = append(rule.ByDay, weekday)
+				}
+			}
+
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, errors.New("frequency is required")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != Weekly {
+		return Rule{}, errors.New("week days are only supported for weekly rules")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, errors.New("count and until can't be used together")
+	}
+
+	slices.Sort(rule.ByDay)
+
+	return rule, nil
+}
+
+// parseDays reads week days such as "Mon/Wed/Fri" as a weekly rule.
+func parseDays(s string) (Rule, error) {
+	rule := Rule{Freq: Weekly, Interval: 1}
+
+	days := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '/' || r == ',' || r == ' '
+	})
+
+	for _, day := range days {
+		weekday, ok := dayNames[strings.ToLower(day)]
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid week day %q", day)
+		}
+		if !slices.Contains(rule.ByDay, weekday) {
+			rule.ByDay = append(rule.ByDay, weekday)
+		}
+	}
+
+	if len(rule.ByDay) == 0 {
+		return Rule{}, errors.New("rule is empty")
+	}
+
+	slices.Sort(rule.ByDay)
+
+	return rule, nil
+}
+
+// String returns the rule in its RFC 5545 form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, weekday := range r.ByDay {
+			days[i] = strings.ToUpper(weekday.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Between returns the dates of the occurrences of the rule starting on
+// start that fall within [from, to]. All dates are midnights in UTC, see
+// Date.
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	if !r.Until.IsZero() && r.Until.Before(to) {
+		to = r.Until
+	}
+
+	byDay := r.ByDay
+	if r.Freq == Weekly && len(byDay) == 0 {
+		byDay = []time.Weekday{start.Weekday()}
+	}
+
+	interval := max(r.Interval, 1)
+
+	// monday of the start's week, weekly intervals are counted in weeks
+	// from it.
+	weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+
+	dates := []time.Time{}
+	count := 0
+
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		var occurs bool
+
+		switch r.Freq {
+		case Daily:
+			occurs = daysBetween(start, day)%interval == 0
+		case Weekly:
+			occurs = (daysBetween(weekStart, day)/7)%interval == 0 && slices.Contains(byDay, day.Weekday())
+		case Monthly:
+			months := (day.Year()-start.Year())*12 + int(day.Month()-start.Month())
+			occurs = day.Day() == start.Day() && months%interval == 0
+		}
+
+		if !occurs {
+			continue
+		}
+
+		count++
+		if r.Count > 0 && count > r.Count {
+			break
+		}
+
+		if !day.Before(from) {
+			dates = append(dates, day)
+		}
+	}
+
+	return dates
+}
+
+// Date returns the midnight in UTC of the date of t in its location, the
+// form of dates used by Between.
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
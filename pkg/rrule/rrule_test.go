@@ -0,0 +1,229 @@
+package rrule
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rule
+		wantErr bool
+	}{
+		{
+			in:   "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			want: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Wednesday, time.Friday}},
+		},
+		{
+			in:   "RRULE:FREQ=DAILY;INTERVAL=2;COUNT=10",
+			want: Rule{Freq: Daily, Interval: 2, Count: 10},
+		},
+		{
+			in:   "freq=monthly;until=20241231T000000Z",
+			want: Rule{Freq: Monthly, Interval: 1, Until: date("2024-12-31")},
+		},
+		{
+			in:   "FREQ=WEEKLY;BYDAY=FR,MO,FR",
+			want: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Friday}},
+		},
+		{
+			in:   "Mon/Wed/Fri",
+			want: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Wednesday, time.Friday}},
+		},
+		{
+			in:   "monday, Thursday",
+			want: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Thursday}},
+		},
+		{
+			in:   "SA,su",
+			want: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Sunday, time.Saturday}},
+		},
+		{in: "", wantErr: true},
+		{in: "FREQ=YEARLY", wantErr: true},
+		{in: "INTERVAL=2", wantErr: true},
+		{in: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=1001", wantErr: true},
+		{in: "FREQ=DAILY;UNTIL=2024-12-31", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=2;UNTIL=20241231", wantErr: true},
+		{in: "FREQ=DAILY;BYDAY=MO", wantErr: true},
+		{in: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		{in: "FREQ=DAILY;BYMONTH=1", wantErr: true},
+		{in: "FREQ", wantErr: true},
+		{in: "Mox/Wed", wantErr: true},
+		{in: "Mondays", wantErr: true},
+		{in: "Thurx", wantErr: true},
+		{in: "M", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{
+			rule: Rule{Freq: Weekly, Interval: 1, ByDay: []time.Weekday{time.Monday, time.Wednesday, time.Friday}},
+			want: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		},
+		{
+			rule: Rule{Freq: Daily, Interval: 2, Count: 5},
+			want: "FREQ=DAILY;INTERVAL=2;COUNT=5",
+		},
+		{
+			rule: Rule{Freq: Monthly, Interval: 1, Until: date("2024-12-31")},
+			want: "FREQ=MONTHLY;UNTIL=20241231",
+		},
+		{
+			rule: Rule{Freq: Weekly, Interval: 3, ByDay: []time.Weekday{time.Sunday}, Count: 4},
+			want: "FREQ=WEEKLY;INTERVAL=3;BYDAY=SU;COUNT=4",
+		},
+	}
+
+	for _, tt := range tests {
+		got := tt.rule.String()
+		if got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.rule, got, tt.want)
+		}
+
+		// the rule reads back the same
+		parsed, err := Parse(got)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", got, err)
+		} else if !reflect.DeepEqual(parsed, tt.rule) {
+			t.Errorf("Parse(%q) = %+v, want %+v", got, parsed, tt.rule)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name            string
+		rule            string
+		start, from, to string
+		want            []string
+	}{
+		{
+			name:  "daily interval",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-07",
+			want: []string{"2024-01-01", "2024-01-03", "2024-01-05", "2024-01-07"},
+		},
+		{
+			name:  "weekly by day",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-07",
+			want: []string{"2024-01-01", "2024-01-03", "2024-01-05"},
+		},
+		{
+			name:  "weekly interval counts weeks from the start's week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-21",
+			want: []string{"2024-01-02", "2024-01-04", "2024-01-16", "2024-01-18"},
+		},
+		{
+			name:  "weekly on the start's week day",
+			rule:  "FREQ=WEEKLY",
+			start: "2024-01-03", from: "2024-01-01", to: "2024-01-17",
+			want: []string{"2024-01-03", "2024-01-10", "2024-01-17"},
+		},
+		{
+			name:  "count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:  "count includes occurrences before from",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2024-01-01", from: "2024-01-02", to: "2024-01-31",
+			want: []string{"2024-01-02", "2024-01-03"},
+		},
+		{
+			name:  "weekly count",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-04", "2024-01-08"},
+		},
+		{
+			name:  "until",
+			rule:  "FREQ=DAILY;UNTIL=20240103",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:  "from after until",
+			rule:  "FREQ=DAILY;UNTIL=20240103",
+			start: "2024-01-01", from: "2024-01-10", to: "2024-01-31",
+			want: []string{},
+		},
+		{
+			name:  "monthly skips months without the day",
+			rule:  "FREQ=MONTHLY",
+			start: "2024-01-31", from: "2024-01-01", to: "2024-05-31",
+			want: []string{"2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			name:  "monthly interval",
+			rule:  "FREQ=MONTHLY;INTERVAL=2",
+			start: "2024-01-15", from: "2024-02-01", to: "2024-07-31",
+			want: []string{"2024-03-15", "2024-05-15", "2024-07-15"},
+		},
+		{
+			name:  "nothing before the start",
+			rule:  "FREQ=DAILY",
+			start: "2024-01-10", from: "2024-01-01", to: "2024-01-09",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("%s: Parse(%q) returned error: %v", tt.name, tt.rule, err)
+			continue
+		}
+
+		got := rule.Between(date(tt.start), date(tt.from), date(tt.to))
+
+		want := make([]time.Time, len(tt.want))
+		for i, s := range tt.want {
+			want[i] = date(s)
+		}
+
+		if !slices.EqualFunc(got, want, time.Time.Equal) {
+			t.Errorf("%s: Between(%s, %s, %s) = %v, want %v", tt.name, tt.start, tt.from, tt.to, got, want)
+		}
+	}
+}
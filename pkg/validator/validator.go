@@ -13,6 +13,7 @@ var (
 	MobileRX     = regexp.MustCompile(`^01[0125][0-9]{8}$`)
 	YearRX       = regexp.MustCompile(`^(19|20)\d{2}$`)
 	URLRX        = regexp.MustCompile(`^(https?|ftp)://[^\s/$.?#].[^\s]*$`)
+	HTTPURLRX    = regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`)
 )
 
 func Matches(value string, rx *regexp.Regexp) bool {
@@ -24,13 +25,15 @@ func In(value string, array []string) bool {
 	return slices.Contains(array, value)
 }
 
+// Validator collects the messages of the invalid fields, a field can fail
+// more than one check.
 type Validator struct {
-	Errors map[string]string
+	Errors map[string][]string
 }
 
 func New() *Validator {
 	return &Validator{
-		Errors: make(map[string]string),
+		Errors: make(map[string][]string),
 	}
 }
 
@@ -39,7 +42,9 @@ func (v *Validator) Valid() bool {
 }
 
 func (v *Validator) AddError(key, message string) {
-	v.Errors[key] = message
+	if !slices.Contains(v.Errors[key], message) {
+		v.Errors[key] = append(v.Errors[key], message)
+	}
 }
 
 func (v *Validator) Check(condition bool, key, message string) {